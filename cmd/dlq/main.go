@@ -0,0 +1,182 @@
+// Command dlq inspects and requeues entries in the conversion job dead-letter queue.
+//
+// The queues are chosen from the environment in the same way as the functions:
+// DLQ_OCID or LOCAL_DLQ_DIR for the dead-letter queue, and QUEUE_OCID or
+// LOCAL_QUEUE_DIR for the main queue.
+//
+// Usage:
+//
+//	dlq list [-n 50]
+//	dlq requeue -all
+//	dlq requeue <message-id>...
+package main
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/queue"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// scanVisibility hides inspected entries from other consumers while the command runs.
+	scanVisibility = 5 * time.Minute
+	batchSize      = 20
+)
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	dlq, err := queue.NewDeadLetterFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create dead-letter queue client: %v", err)
+	}
+	if dlq == nil {
+		log.Fatal("No dead-letter queue configured: set DLQ_OCID or LOCAL_DLQ_DIR")
+	}
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		limit := fs.Int("n", 50, "maximum number of entries to show")
+		fs.Parse(os.Args[2:])
+
+		if err := list(ctx, dlq, *limit); err != nil {
+			log.Fatal(err)
+		}
+	case "requeue":
+		fs := flag.NewFlagSet("requeue", flag.ExitOnError)
+		all := fs.Bool("all", false, "requeue every entry")
+		fs.Parse(os.Args[2:])
+		if !*all && fs.NArg() == 0 {
+			usage()
+		}
+
+		jobQueue, err := queue.NewFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to create job queue client: %v", err)
+		}
+		if err := requeue(ctx, dlq, jobQueue, *all, fs.Args()); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		usage()
+	}
+}
+
+// entry is the printed form of a dead-letter queue entry.
+type entry struct {
+	MessageID      string   `json:"messageId"`
+	DownloadID     string   `json:"downloadId,omitempty"`
+	URLs           int      `json:"urls"`
+	Attempts       int      `json:"attempts"`
+	LastError      string   `json:"lastError,omitempty"`
+	DeadLetteredAt string   `json:"deadLetteredAt,omitempty"`
+	RawMessage     string   `json:"rawMessage,omitempty"`
+	SampleURLs     []string `json:"sampleUrls,omitempty"`
+}
+
+// list prints up to limit entries as JSON lines and then releases them.
+func list(ctx context.Context, dlq queue.JobQueue, limit int) error {
+	var seen []queue.Message
+	defer func() { release(ctx, dlq, seen) }()
+
+	enc := json.NewEncoder(os.Stdout)
+	for len(seen) < limit {
+		messages, err := dlq.Receive(ctx, min(batchSize, limit-len(seen)), scanVisibility)
+		if err != nil {
+			return fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			seen = append(seen, msg)
+
+			e := entry{MessageID: msg.ID}
+			if job, err := msg.Job(); err != nil {
+				e.RawMessage = msg.Content
+			} else {
+				e.DownloadID = job.DownloadID
+				e.URLs = len(job.URLs)
+				e.Attempts = job.Attempts
+				e.LastError = job.LastError
+				e.DeadLetteredAt = job.DeadLetteredAt
+				e.RawMessage = job.RawMessage
+				e.SampleURLs = job.URLs[:min(3, len(job.URLs))]
+			}
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(seen) == 0 {
+		log.Print("Dead-letter queue is empty")
+	}
+	return nil
+}
+
+// requeue moves the selected entries back onto the main queue.
+func requeue(ctx context.Context, dlq, jobQueue queue.JobQueue, all bool, ids []string) error {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var skipped []queue.Message
+	defer func() { release(ctx, dlq, skipped) }()
+
+	requeued, failed := 0, 0
+	for {
+		messages, err := dlq.Receive(ctx, batchSize, scanVisibility)
+		if err != nil {
+			return fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			if !all && !wanted[msg.ID] {
+				skipped = append(skipped, msg)
+				continue
+			}
+			if err := queue.Requeue(ctx, dlq, jobQueue, msg); err != nil {
+				log.Printf("Failed to requeue message %s: %v", msg.ID, err)
+				skipped = append(skipped, msg)
+				failed++
+				continue
+			}
+			log.Printf("Requeued message %s", msg.ID)
+			requeued++
+		}
+	}
+
+	log.Printf("Requeued %d entries, %d failed", requeued, failed)
+	return nil
+}
+
+// release makes inspected entries visible again.
+func release(ctx context.Context, dlq queue.JobQueue, messages []queue.Message) {
+	for _, msg := range messages {
+		if err := dlq.Nack(ctx, msg.Receipt); err != nil {
+			log.Printf("Failed to release message %s: %v", msg.ID, err)
+		}
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dlq list [-n 50] | dlq requeue (-all | <message-id>...)")
+	os.Exit(2)
+}
@@ -0,0 +1,365 @@
+// Command doc-converter converts web pages to Markdown or HTML files on the
+// local machine, using the same converter as the serverless functions.
+//
+// Usage:
+//
+//	doc-converter convert [flags] [URL...]
+//	doc-converter crawl [flags] <start-URL>
+//
+// convert reads URLs from its arguments, from the file given with -i ("-" for
+// stdin), or from stdin when neither is given; blank lines and lines starting
+// with # are skipped. crawl converts the start URL and the pages it links to
+// on the same host and under the same directory. Both commands can bundle the
+// converted files into a zip archive with -zip.
+//
+// Pages can be extracted with per-site rules from the YAML profiles file given
+// with -profiles or named by EXTRACTION_PROFILES.
+//
+// Private and loopback addresses are refused unless permitted with the
+// SSRF_ALLOW_CIDRS and SSRF_ALLOWED_PORTS environment variables.
+//
+// Progress is written to stderr and the summary report to stdout. The exit
+// status is 1 if any URL failed or was cancelled, 2 for invalid usage and 3 if
+// the run could not be completed.
+package main
+
+import (
+	"bufio"
+	"context"
+	"doc-converter-oci-serverless/pkg/api"
+	"doc-converter-oci-serverless/pkg/converter"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Exit statuses.
+const (
+	exitOK      = 0
+	exitFailed  = 1 // At least one URL failed or was cancelled
+	exitUsage   = 2 // Invalid arguments or options
+	exitRuntime = 3 // The run could not be completed, e.g. the archive could not be written
+)
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var code int
+	switch os.Args[1] {
+	case "convert":
+		code = runConvert(ctx, os.Args[2:])
+	case "crawl":
+		code = runCrawl(ctx, os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		log.Printf("unknown command %q", os.Args[1])
+		usage()
+	}
+	stop()
+	os.Exit(code)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage:
+  doc-converter convert [flags] [URL...]
+  doc-converter crawl [flags] <start-URL>
+
+Run "doc-converter <command> -h" for the flags of a command.`)
+	os.Exit(exitUsage)
+}
+
+// runConvert implements the convert command.
+func runConvert(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	cfg := registerFlags(fs)
+	input := fs.String("i", "", `file to read URLs from, one per line ("-" for stdin)`)
+	fs.Parse(args)
+
+	urls := fs.Args()
+	if *input != "" || len(urls) == 0 {
+		fromInput, err := readURLs(*input)
+		if err != nil {
+			log.Printf("Failed to read URLs: %v", err)
+			return exitUsage
+		}
+		urls = append(urls, fromInput...)
+	}
+
+	req, ok := cfg.request(urls)
+	if !ok {
+		return exitUsage
+	}
+
+	c, err := cfg.converter(req.Options)
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+
+	results, summary := c.ConvertContext(ctx, req.URLs, req.Selector)
+	return cfg.finish(ctx, c, results, summary, len(req.URLs))
+}
+
+// runCrawl implements the crawl command.
+func runCrawl(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	cfg := registerFlags(fs)
+	depth := fs.Int("depth", 2, "number of link hops to follow from the start URL")
+	maxPages := fs.Int("max-pages", 100, "maximum number of pages to convert")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Print("crawl takes exactly one start URL")
+		return exitUsage
+	}
+
+	req, ok := cfg.request(fs.Args())
+	if !ok {
+		return exitUsage
+	}
+
+	c, err := cfg.converter(req.Options)
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+
+	results, summary := c.Crawl(ctx, req.URLs[0], req.Selector, converter.CrawlOptions{
+		MaxDepth: *depth,
+		MaxPages: *maxPages,
+	})
+	return cfg.finish(ctx, c, results, summary, 0)
+}
+
+// config holds the flags shared by every command.
+type config struct {
+	outputDir string
+	selector  string
+	profiles  string
+	zipPath   string
+	report    string
+	quiet     bool
+	verbose   bool
+	headers   headerFlag
+	options   converter.ConversionOptions
+	timeout   time.Duration
+}
+
+// registerFlags defines the shared flags on fs.
+func registerFlags(fs *flag.FlagSet) *config {
+	cfg := &config{}
+	fs.StringVar(&cfg.outputDir, "o", "output", "directory to write converted files to")
+	fs.StringVar(&cfg.selector, "selector", "", "CSS selector for the main content (default: detect automatically)")
+	fs.StringVar(&cfg.profiles, "profiles", "", "YAML file of per-site extraction profiles (default: $EXTRACTION_PROFILES)")
+	fs.StringVar(&cfg.zipPath, "zip", "", "also write the converted files and a manifest to this zip archive")
+	fs.StringVar(&cfg.report, "report", "text", `summary report format, "text" or "json"`)
+	fs.BoolVar(&cfg.quiet, "q", false, "do not print progress")
+	fs.BoolVar(&cfg.verbose, "v", false, "print converter log messages")
+	fs.Var(&cfg.headers, "H", `extra request header as "Name: value" (repeatable)`)
+	fs.Func("exclude", "CSS selector for elements to drop from the content (repeatable)", func(selector string) error {
+		cfg.options.Extraction.Exclude = append(cfg.options.Extraction.Exclude, selector)
+		return nil
+	})
+	fs.BoolVar(&cfg.options.Extraction.KeepBoilerplate, "keep-boilerplate", false, "keep scripts, navigation, forms and hidden elements in the content")
+
+	fs.StringVar(&cfg.options.OutputFormat, "format", converter.FormatMarkdown, `output format, "markdown" or "html"`)
+	fs.StringVar(&cfg.options.FrontMatter.Format, "front-matter", converter.FrontMatterYAML, `front matter format, "yaml", "toml", "json" or "none"`)
+	fs.Func("field", `extra front matter field as "name=value" (repeatable); the value may be a YAML scalar or list or a template such as {{.Meta.title}}, and an empty value removes the field`, cfg.setField)
+	fs.StringVar(&cfg.options.FilenameTemplate, "filename", "", "text/template for output file names, e.g. {{.Host}}_{{.Slug}}")
+	fs.StringVar(&cfg.options.UserAgent, "user-agent", "", "User-Agent header sent with every request")
+	fs.IntVar(&cfg.options.Workers, "workers", 0, "number of URLs converted concurrently")
+	fs.IntVar(&cfg.options.MaxPerHost, "max-per-host", 0, "maximum concurrent requests to one host")
+	fs.Int64Var(&cfg.options.MaxBodySize, "max-body-size", 0, "maximum response size in bytes")
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "per-request timeout, e.g. 10s")
+	return cfg
+}
+
+// request builds and validates the conversion request for urls, printing any
+// problems. It reports false if the request is invalid.
+func (cfg *config) request(urls []string) (*api.ConversionRequest, bool) {
+	if cfg.report != "text" && cfg.report != "json" {
+		log.Printf("unsupported report format %q", cfg.report)
+		return nil, false
+	}
+
+	options := cfg.options
+	options.Timeout = converter.Duration(cfg.timeout)
+	options.Headers = cfg.headers
+
+	req := &api.ConversionRequest{URLs: urls, Selector: cfg.selector, Options: &options}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		var validationErr *api.ValidationError
+		if !errors.As(err, &validationErr) {
+			log.Print(err)
+			return nil, false
+		}
+		for _, field := range validationErr.Fields {
+			log.Printf("%s: %s", field.Field, field.Message)
+		}
+		return nil, false
+	}
+	return req, true
+}
+
+// converter creates the Converter writing to the output directory. Unless -v
+// is given, log output is silenced until finish, since converter log messages
+// duplicate the progress output.
+func (cfg *config) converter(options *converter.ConversionOptions) (*converter.Converter, error) {
+	if cfg.profiles != "" {
+		profiles, err := converter.LoadProfiles(cfg.profiles)
+		if err != nil {
+			return nil, err
+		}
+		options.Profiles = profiles
+	}
+
+	c, err := converter.NewConverterForCLI(cfg.outputDir, converter.WithConversionOptions(options))
+	if err == nil && !cfg.verbose {
+		log.SetOutput(io.Discard)
+	}
+	return c, err
+}
+
+// finish prints progress for every result, then the summary report, writes the
+// archive if requested and returns the exit status. total is the number of
+// URLs expected, or 0 if unknown.
+func (cfg *config) finish(ctx context.Context, c *converter.Converter, resultsChan <-chan converter.Result, summaryChan <-chan converter.Summary, total int) int {
+	var results []converter.Result
+	for result := range resultsChan {
+		result.Content = nil // Already written to the output directory
+		results = append(results, result)
+		if !cfg.quiet {
+			printProgress(os.Stderr, len(results), total, result)
+		}
+	}
+	summary := <-summaryChan
+
+	log.SetOutput(os.Stderr)
+
+	if err := writeReport(os.Stdout, cfg.report, summary); err != nil {
+		log.Printf("Failed to write report: %v", err)
+		return exitRuntime
+	}
+
+	if cfg.zipPath != "" {
+		if err := writeArchive(context.WithoutCancel(ctx), cfg.zipPath, c, results, summary); err != nil {
+			log.Printf("Failed to write archive: %v", err)
+			return exitRuntime
+		}
+		if !cfg.quiet {
+			fmt.Fprintf(os.Stderr, "Archive written to %s\n", cfg.zipPath)
+		}
+	}
+
+	if summary.Failed > 0 || summary.Cancelled > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// readURLs reads one URL per line from the named file, or from stdin when name
+// is "" or "-". Blank lines and lines starting with # are skipped.
+func readURLs(name string) ([]string, error) {
+	in := os.Stdin
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// setField parses a -field flag. Values that are YAML scalars or lists of
+// scalars keep their type, so "weight=10" gives a number and "tags=[a, b]" a
+// list; anything else, including templates, is used as a string. An empty
+// value removes the field.
+func (cfg *config) setField(value string) error {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf(`field must be "name=value"`)
+	}
+
+	var field interface{}
+	if raw != "" {
+		field = raw
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(raw), &parsed); err == nil && isScalarOrList(parsed) {
+			field = parsed
+		}
+	}
+
+	if cfg.options.FrontMatter.Fields == nil {
+		cfg.options.FrontMatter.Fields = make(map[string]interface{})
+	}
+	cfg.options.FrontMatter.Fields[strings.TrimSpace(name)] = field
+	return nil
+}
+
+// isScalarOrList reports whether a decoded YAML value is a non-null scalar or
+// a list of such scalars.
+func isScalarOrList(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, map[interface{}]interface{}:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if item == nil || !isScalarOrList(item) {
+				return false
+			}
+			if _, nested := item.([]interface{}); nested {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// headerFlag collects repeated -H "Name: value" flags.
+type headerFlag map[string]string
+
+func (h *headerFlag) String() string {
+	return fmt.Sprint(map[string]string(*h))
+}
+
+func (h *headerFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf(`header must be "Name: value"`)
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	(*h)[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/converter"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// printProgress writes one line for a finished URL. n is the number of URLs
+// finished so far and total the number expected, or 0 if unknown.
+func printProgress(w io.Writer, n, total int, result converter.Result) {
+	counter := fmt.Sprintf("[%d]", n)
+	if total > 0 {
+		counter = fmt.Sprintf("[%d/%d]", n, total)
+	}
+
+	switch {
+	case result.IsSuccess && result.ContentPath != "":
+		fmt.Fprintf(w, "%s ok     %s -> %s (content: %s)\n", counter, result.URL, result.FileName, result.ContentPath)
+	case result.IsSuccess:
+		fmt.Fprintf(w, "%s ok     %s -> %s\n", counter, result.URL, result.FileName)
+	case result.Cancelled:
+		fmt.Fprintf(w, "%s cancel %s\n", counter, result.URL)
+	default:
+		fmt.Fprintf(w, "%s FAIL   %s: %s\n", counter, result.URL, result.Error)
+	}
+}
+
+// writeReport writes the run summary in the given format, "text" or "json".
+func writeReport(w io.Writer, format string, summary converter.Summary) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summary)
+	}
+
+	fmt.Fprintf(w, "Converted %d of %d URLs in %s\n", summary.Successful, summary.TotalURLs, summary.ProcessingTime)
+	if summary.Failed > 0 {
+		fmt.Fprintf(w, "Failed (%d):\n", summary.Failed)
+		for _, u := range summary.FailedURLs {
+			fmt.Fprintf(w, "  %s\n", u)
+		}
+	}
+	if summary.Cancelled > 0 {
+		fmt.Fprintf(w, "Cancelled (%d):\n", summary.Cancelled)
+		for _, u := range summary.CancelledURLs {
+			fmt.Fprintf(w, "  %s\n", u)
+		}
+	}
+	return nil
+}
+
+// writeArchive bundles the converted files and a manifest into a zip file at path.
+func writeArchive(ctx context.Context, path string, c *converter.Converter, results []converter.Result, summary converter.Summary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := converter.WriteArchive(ctx, f, c.Storage, results, summary); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
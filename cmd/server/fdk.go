@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fnproject/fdk-go"
+)
+
+// function adapts an fdk.Handler to net/http, giving it the same HTTP context
+// the API Gateway provides in OCI.
+func function(h fdk.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := fdk.WithContext(r.Context(), httpContext{req: r})
+		h.Serve(ctx, r.Body, w)
+	})
+}
+
+// httpContext implements fdk.HTTPContext for a local HTTP request.
+type httpContext struct {
+	req *http.Request
+}
+
+func (c httpContext) Config() map[string]string { return map[string]string{} }
+func (c httpContext) Header() http.Header       { return c.req.Header }
+func (c httpContext) ContentType() string       { return c.req.Header.Get("Content-Type") }
+func (c httpContext) CallID() string            { return "" }
+func (c httpContext) AppID() string             { return "" }
+func (c httpContext) FnID() string              { return "" }
+func (c httpContext) AppName() string           { return "doc-converter" }
+func (c httpContext) FnName() string            { return "" }
+func (c httpContext) RequestURL() string        { return c.req.URL.RequestURI() }
+func (c httpContext) RequestMethod() string     { return c.req.Method }
+
+func (c httpContext) TracingContextData() fdk.TracingContext { return noTracing{} }
+
+// noTracing is a TracingContext with tracing disabled.
+type noTracing struct{}
+
+func (noTracing) IsTracingEnabled() bool    { return false }
+func (noTracing) TraceCollectorURL() string { return "" }
+func (noTracing) TraceId() string           { return "" }
+func (noTracing) SpanId() string            { return "" }
+func (noTracing) ParentSpanId() string      { return "" }
+func (noTracing) IsSampled() bool           { return false }
+func (noTracing) Flags() string             { return "" }
+func (noTracing) ServiceName() string       { return "" }
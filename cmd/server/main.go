@@ -0,0 +1,173 @@
+// Command server runs the whole conversion pipeline in a single process for
+// local development. It mounts the create-job, get-job-status and
+// download-job handlers on the same routes as the API Gateway, runs the
+// process-job handler on in-process workers fed by an in-memory queue, and
+// serves the frontend.
+//
+// Job records and archives are written under tmp/downloads unless
+// OUTPUT_BUCKET_NAME is set, in which case the output bucket is used exactly
+// as in OCI. Pages on this machine are refused by the SSRF policy; set
+// SSRF_ALLOW_CIDRS=127.0.0.1 and SSRF_ALLOWED_PORTS=* to convert them. Set
+// EXTRACTION_PROFILES to a profiles file, or to an oci://<bucket>/<object>
+// location as the deployed functions do, to extract pages with per-site rules.
+//
+// Usage:
+//
+//	server [-addr :8080] [-workers 2] [-frontend frontend/index.html] [-api-base /api/v1]
+package main
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/converter"
+	"doc-converter-oci-serverless/pkg/handlers"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"doc-converter-oci-serverless/pkg/queue"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// pollInterval is how long an idle worker waits before checking the queue again.
+	pollInterval = time.Second
+	// receiveVisibility hides a received message until the handler takes over
+	// extending its visibility.
+	receiveVisibility = 2 * time.Minute
+	shutdownTimeout   = 10 * time.Second
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	workers := flag.Int("workers", 2, "number of concurrent process-job workers")
+	frontend := flag.String("frontend", filepath.Join("frontend", "index.html"), "path of the frontend page")
+	apiBase := flag.String("api-base", "/api/v1", "API base URL used by the frontend")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobQueue := queue.NewMemoryQueue()
+
+	download := &handlers.Download{}
+	if os.Getenv("OUTPUT_BUCKET_NAME") == "" {
+		download.Link = func(ctx context.Context, jobID string) (string, error) {
+			return "/downloads/" + converter.ArchiveName(jobID), nil
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/jobs", function(&handlers.CreateJob{Queue: jobQueue}))
+	mux.Handle("GET /api/v1/jobs/{id}/status", function(&handlers.JobStatus{}))
+	mux.Handle("GET /api/v1/jobs/{id}/download", function(download))
+	mux.HandleFunc("GET /downloads/{name}", serveArchive)
+	mux.HandleFunc("GET /{$}", serveFrontend(*frontend, *apiBase))
+
+	// Run process-job on in-process workers instead of a queue trigger
+	processor := &handlers.ProcessJob{Queue: jobQueue, Policy: queue.RetryPolicyFromEnv()}
+	var wg sync.WaitGroup
+	for range max(*workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx, jobQueue, processor)
+		}()
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("WARN: Failed to shut down server: %v", err)
+		}
+	}()
+
+	log.Printf("INFO: Listening on %s with %d worker(s)", *addr, max(*workers, 1))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Server failed: %v", err)
+	}
+	wg.Wait()
+}
+
+// work receives messages one at a time and hands them to the process-job
+// handler until ctx is cancelled. Jobs interrupted by shutdown are requeued by
+// the handler and lost with the in-memory queue.
+func work(ctx context.Context, jobQueue queue.JobQueue, processor *handlers.ProcessJob) {
+	for ctx.Err() == nil {
+		messages, err := jobQueue.Receive(ctx, 1, receiveVisibility)
+		if err != nil {
+			log.Printf("ERROR: Failed to receive messages: %v", err)
+		}
+		if len(messages) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		processor.Process(ctx, messages)
+	}
+}
+
+// serveArchive serves a job archive from the local output directory.
+func serveArchive(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	jobID, ok := strings.CutSuffix(name, ".zip")
+	if !ok || !jobid.IsValid(jobID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	root, err := converter.NewFileStorage(filepath.Join("tmp", "downloads"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	archive, err := root.Open(r.Context(), name)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if _, err := io.Copy(w, archive); err != nil {
+		log.Printf("WARN: Failed to send archive %s: %v", name, err)
+	}
+}
+
+// serveFrontend serves the frontend page with its API base URL set to apiBase.
+// The page is read on every request so that edits show up on reload.
+func serveFrontend(path, apiBase string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, "failed to read frontend: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// json.Marshal escapes <, > and &, so the value is safe inside a script tag
+		base, _ := json.Marshal(apiBase)
+		config := "<script>window.API_BASE_URL = " + string(base) + ";</script>\n</head>"
+		html := strings.Replace(string(page), "</head>", config, 1)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, html)
+	}
+}
@@ -0,0 +1,126 @@
+// Package api defines the request and response types shared by the HTTP functions.
+package api
+
+import (
+	"doc-converter-oci-serverless/pkg/converter"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+)
+
+const (
+	// MaxURLs is the maximum number of URLs accepted in a single conversion request.
+	MaxURLs = 5000
+	// maxURLLength is the maximum length of a single URL.
+	maxURLLength = 2048
+)
+
+// ConversionRequest is the body of a create-job request.
+type ConversionRequest struct {
+	URLs     []string                     `json:"urls"`
+	Selector string                       `json:"selector"`
+	Options  *converter.ConversionOptions `json:"options,omitempty"`
+}
+
+// Normalize trims whitespace, drops blank entries and removes duplicate URLs,
+// keeping the first occurrence. URLs that differ only in the case of the scheme
+// or host, or in their fragment, are treated as duplicates.
+func (r *ConversionRequest) Normalize() {
+	r.Selector = strings.TrimSpace(r.Selector)
+
+	seen := make(map[string]bool, len(r.URLs))
+	urls := make([]string, 0, len(r.URLs))
+	for _, u := range r.URLs {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+
+		key := u
+		if parsedURL, err := url.Parse(u); err == nil {
+			parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+			parsedURL.Host = strings.ToLower(parsedURL.Host)
+			parsedURL.Fragment = ""
+			parsedURL.RawFragment = ""
+			key = parsedURL.String()
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		urls = append(urls, u)
+	}
+	r.URLs = urls
+}
+
+// Validate checks the request and returns a *ValidationError listing every
+// invalid field, or nil if the request is acceptable.
+func (r *ConversionRequest) Validate() error {
+	var errs ValidationError
+
+	switch {
+	case len(r.URLs) == 0:
+		errs.Add("urls", "at least one URL is required")
+	case len(r.URLs) > MaxURLs:
+		errs.Add("urls", fmt.Sprintf("at most %d URLs are allowed, got %d", MaxURLs, len(r.URLs)))
+	default:
+		for i, u := range r.URLs {
+			if err := validateURL(u); err != nil {
+				errs.Add(fmt.Sprintf("urls[%d]", i), err.Error())
+			}
+		}
+	}
+
+	selector := r.Selector
+	if selector == "" && r.Options != nil {
+		selector = r.Options.Extraction.Selector
+	}
+	// Without a selector the main content of each page is found automatically
+	if selector != "" {
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			errs.Add("selector", fmt.Sprintf("invalid CSS selector: %v", err))
+		}
+	}
+
+	if r.Options != nil {
+		var optionsErr *converter.OptionsError
+		if err := r.Options.Validate(); errors.As(err, &optionsErr) {
+			for _, field := range optionsErr.Fields {
+				errs.Add("options."+field.Field, field.Message)
+			}
+		} else if err != nil {
+			errs.Add("options", err.Error())
+		}
+	}
+
+	if len(errs.Fields) > 0 {
+		return &errs
+	}
+	return nil
+}
+
+// validateURL checks that u is an absolute http(s) URL without embedded credentials.
+func validateURL(u string) error {
+	if len(u) > maxURLLength {
+		return fmt.Errorf("URL exceeds %d characters", maxURLLength)
+	}
+
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("URL scheme must be http or https")
+	}
+	if parsedURL.Hostname() == "" {
+		return fmt.Errorf("URL must include a host")
+	}
+	if parsedURL.User != nil {
+		return fmt.Errorf("URL must not contain credentials")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/fnproject/fdk-go"
+)
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects the field errors found while validating a request.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add records a problem with the named field.
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// ErrorResponse is the JSON body returned for every failed request.
+type ErrorResponse struct {
+	Error   string       `json:"error"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// WriteJSON writes v as the JSON response body with the given HTTP status.
+func WriteJSON(out io.Writer, status int, v interface{}) {
+	fdk.SetHeader(out, "Content-Type", "application/json")
+	fdk.WriteStatus(out, status)
+	if err := json.NewEncoder(out).Encode(v); err != nil {
+		log.Printf("ERROR: Failed to write response: %v", err)
+	}
+}
+
+// WriteError writes an ErrorResponse with the given HTTP status.
+func WriteError(out io.Writer, status int, message string, details ...FieldError) {
+	WriteJSON(out, status, ErrorResponse{Error: message, Details: details})
+}
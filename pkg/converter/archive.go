@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ManifestName is the name of the manifest file added to every job archive.
+const ManifestName = "manifest.json"
+
+// Manifest describes the contents of a job archive.
+type Manifest struct {
+	DownloadID string    `json:"downloadId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Summary    Summary   `json:"summary"`
+	Results    []Result  `json:"results"`
+}
+
+// ArchiveName returns the name of the zip archive for a job, as expected by download-job.
+func ArchiveName(downloadID string) string {
+	return downloadID + ".zip"
+}
+
+// WriteArchive streams every successful result file from src into a zip written
+// to w, followed by a manifest listing all results and the summary.
+func WriteArchive(ctx context.Context, w io.Writer, src Storage, results []Result, summary Summary) error {
+	zw := zip.NewWriter(w)
+
+	for _, result := range results {
+		if !result.IsSuccess {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := addArchiveFile(ctx, zw, src, result.FileName); err != nil {
+			return err
+		}
+	}
+
+	manifest := Manifest{
+		DownloadID: summary.DownloadID,
+		CreatedAt:  time.Now().UTC(),
+		Summary:    summary,
+		Results:    results,
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	mw, err := zw.Create(ManifestName)
+	if err != nil {
+		return fmt.Errorf("failed to add manifest to archive: %w", err)
+	}
+	if _, err := mw.Write(manifestJSON); err != nil {
+		return fmt.Errorf("failed to add manifest to archive: %w", err)
+	}
+
+	return zw.Close()
+}
+
+// StoreArchive builds the archive for a finished job and stores it in dst under
+// ArchiveName(summary.DownloadID). The converted files are read from src. When
+// dst is a StreamStorage, the zip is streamed to it as it is written; otherwise
+// it is built in memory first.
+func StoreArchive(ctx context.Context, dst, src Storage, results []Result, summary Summary) error {
+	if summary.DownloadID == "" {
+		return fmt.Errorf("downloadID cannot be empty when storing a job archive")
+	}
+	name := ArchiveName(summary.DownloadID)
+
+	streamer, ok := dst.(StreamStorage)
+	if !ok {
+		var buf bytes.Buffer
+		if err := WriteArchive(ctx, &buf, src, results, summary); err != nil {
+			return err
+		}
+		if err := dst.Put(ctx, name, buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to store archive %s: %w", name, err)
+		}
+		return nil
+	}
+
+	// A failure to write the zip reaches PutStream as a read error, and a failed
+	// upload unblocks the writer by closing the pipe
+	pr, pw := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := WriteArchive(ctx, pw, src, results, summary)
+		pw.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	err := streamer.PutStream(ctx, name, pr)
+	pr.Close()
+	if werr := <-writeErr; err == nil && werr != nil {
+		return werr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store archive %s: %w", name, err)
+	}
+	return nil
+}
+
+// addArchiveFile copies a single file from src into the zip.
+func addArchiveFile(ctx context.Context, zw *zip.Writer, src Storage, name string) error {
+	r, err := src.Open(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for archiving: %w", name, err)
+	}
+	defer r.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+
+	if _, err := io.Copy(fw, r); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package converter
+
+import (
+	"doc-converter-oci-serverless/pkg/ssrf"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrNonPublicAddress is returned, wrapped, when a request would connect to a
+// destination blocked by the SSRF policy.
+var ErrNonPublicAddress = ssrf.ErrBlocked
+
+// newHTTPClient returns the client used to fetch pages. The validator is
+// applied when each connection is dialled, after DNS resolution, so a
+// hostname cannot pass a check and then resolve to an internal address, and
+// every redirect hop is checked as well. Proxies are not used, since the
+// address checked would then be the proxy rather than the page's host.
+func newHTTPClient(timeout time.Duration, validator ssrf.Validator) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   ssrf.Control(validator),
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: ssrf.CheckRedirect(validator),
+	}
+}
@@ -3,23 +3,32 @@ package converter
 
 import (
 	"bytes"
+	"context"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"doc-converter-oci-serverless/pkg/ssrf"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
 	"path/filepath"
-	"regexp"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"gopkg.in/yaml.v2"
 )
 
 const (
 	maxBodySize = 5 * 1024 * 1024 // 5MB
 	httpTimeout = 5 * time.Second
+
+	defaultWorkers    = 8 // URLs converted concurrently per job
+	defaultMaxPerHost = 2 // In-flight requests allowed against a single host
 )
 
 // Result holds the outcome of a single URL conversion.
@@ -29,6 +38,10 @@ type Result struct {
 	Content   []byte `json:"-"` // Exclude raw content from logs. Kept for CLI compatibility.
 	Error     string `json:"error,omitempty"`
 	IsSuccess bool   `json:"isSuccess"`
+	Cancelled bool   `json:"cancelled,omitempty"` // Set when the conversion was stopped by context cancellation
+	// ContentPath is the CSS selector of the element chosen by automatic
+	// extraction; it can be used as the selector for later conversions.
+	ContentPath string `json:"contentPath,omitempty"`
 }
 
 // Summary provides a final overview of the batch conversion.
@@ -37,6 +50,8 @@ type Summary struct {
 	Successful     int      `json:"successful"`
 	Failed         int      `json:"failed"`
 	FailedURLs     []string `json:"failedUrls"`
+	Cancelled      int      `json:"cancelled"`
+	CancelledURLs  []string `json:"cancelledUrls,omitempty"`
 	ProcessingTime string   `json:"processingTime"`
 	DownloadID     string   `json:"downloadId,omitempty"` // ID for the final zip file
 }
@@ -46,171 +61,220 @@ type Converter struct {
 	Client     *http.Client
 	OutputDir  string
 	DownloadID string
+
+	// Workers is the number of URLs converted concurrently.
+	Workers int
+	// MaxPerHost is the maximum number of in-flight requests to any single host.
+	MaxPerHost int
+
+	// Options holds the resolved conversion settings.
+	Options ConversionOptions
+	// Storage receives the converted files. When nil, files are written to OutputDir.
+	Storage Storage
+	// FilePrefix is prepended to every output file name. Converters sharing a
+	// Storage, such as the shards of one job, use distinct prefixes so that
+	// they can never overwrite each other's files.
+	FilePrefix string
+
+	filenameTemplate *template.Template
+	fieldTemplates   map[string]*template.Template
+	validator        ssrf.Validator
+
+	namesMu sync.Mutex
+	names   map[string]bool // File names handed out so far, to keep them unique
+
 }
 
 // NewConverterForJob creates a new Converter for a background job.
 // It uses the downloadID to create a unique, predictable directory for output files.
-func NewConverterForJob(downloadID string) (*Converter, error) {
+func NewConverterForJob(downloadID string, opts ...Option) (*Converter, error) {
 	if downloadID == "" {
 		return nil, fmt.Errorf("downloadID cannot be empty for a job-based conversion")
 	}
+	// The ID becomes part of a filesystem path, so it must be a well-formed job ID
+	if err := jobid.Validate(downloadID); err != nil {
+		return nil, err
+	}
 
-	outputDir := filepath.Join("tmp", "downloads", downloadID)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+	storage, err := NewFileStorage(filepath.Join("tmp", "downloads", downloadID))
+	if err != nil {
+		return nil, err
 	}
 
-	return &Converter{
-		Client: &http.Client{
-			Timeout: httpTimeout,
-		},
-		OutputDir:  outputDir,
-		DownloadID: downloadID,
-	}, nil
+	c, err := NewConverterWithStorage(downloadID, storage, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.OutputDir = storage.Dir
+	return c, nil
 }
 
 // NewConverterForCLI creates a new Converter for a command-line execution.
 // It uses a user-provided directory path for the output.
-func NewConverterForCLI(outputDir string) (*Converter, error) {
+func NewConverterForCLI(outputDir string, opts ...Option) (*Converter, error) {
 	if outputDir == "" {
 		return nil, fmt.Errorf("output directory must be specified for CLI conversion")
 	}
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+	storage, err := NewFileStorage(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// DownloadID is not relevant for CLI runs.
+	c, err := NewConverterWithStorage("", storage, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.OutputDir = storage.Dir
+	return c, nil
+}
+
+// NewConverterWithStorage creates a new Converter that writes its output to the
+// given Storage, such as an Object Storage bucket or an in-memory store.
+func NewConverterWithStorage(downloadID string, storage Storage, opts ...Option) (*Converter, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage cannot be nil")
+	}
+	if downloadID != "" {
+		if err := jobid.Validate(downloadID); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := newConverter(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.DownloadID = downloadID
+	c.Storage = storage
+	return c, nil
+}
+
+// newConverter builds a Converter from the defaults overridden by opts.
+func newConverter(opts []Option) (*Converter, error) {
+	options, err := resolveOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid conversion options: %w", err)
+	}
+
+	tmpl, err := template.New("filename").Option("missingkey=zero").Parse(options.FilenameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+
+	fieldTemplates, err := parseFieldTemplates(options.FrontMatter.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	validator := options.URLValidator
+	if validator == nil {
+		policy, err := ssrf.PolicyFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSRF policy: %w", err)
+		}
+		validator = policy
+	}
+
+	if options.Profiles == nil {
+		profiles, err := ProfilesFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("invalid extraction profiles: %w", err)
+		}
+		options.Profiles = profiles
 	}
 
 	return &Converter{
-		Client: &http.Client{
-			Timeout: httpTimeout,
-		},
-		OutputDir: outputDir,
-		// DownloadID is not relevant for CLI runs.
+		Client:           newHTTPClient(time.Duration(options.Timeout), validator),
+		Workers:          options.Workers,
+		MaxPerHost:       options.MaxPerHost,
+		Options:          options,
+		filenameTemplate: tmpl,
+		fieldTemplates:   fieldTemplates,
+		validator:        validator,
 	}, nil
 }
 
 // Convert orchestrates the fetching, parsing, and conversion of multiple URLs concurrently.
+// It is equivalent to ConvertContext with a background context.
 func (c *Converter) Convert(urls []string, selector string) (<-chan Result, <-chan Summary) {
+	return c.ConvertContext(context.Background(), urls, selector)
+}
+
+// ConvertContext orchestrates the fetching, parsing, and conversion of multiple URLs concurrently.
+// At most Workers URLs are processed at once, and no more than MaxPerHost of them
+// target the same host. Results are streamed as each URL completes.
+//
+// An empty selector falls back to Options.Extraction.Selector; if that is empty
+// too, the main content of each page is found automatically. URLs matching one
+// of Options.Profiles are extracted with the rules of that profile instead.
+//
+// When ctx is cancelled, in-flight requests are aborted and every URL that has not
+// finished is reported with Cancelled set. Both channels are always closed.
+func (c *Converter) ConvertContext(ctx context.Context, urls []string, selector string) (<-chan Result, <-chan Summary) {
+	return c.convertAll(ctx, urls, selector, nil)
+}
+
+// convertAll runs the worker pool behind ConvertContext. When visit is not nil,
+// it is called from the worker goroutines with every page that was fetched and
+// parsed, whether or not its conversion succeeded.
+func (c *Converter) convertAll(ctx context.Context, urls []string, selector string, visit func(u string, doc *goquery.Document)) (<-chan Result, <-chan Summary) {
+	if selector == "" {
+		selector = c.Options.Extraction.Selector
+	}
+
 	resultsChan := make(chan Result)
 	summaryChan := make(chan Summary)
 
 	go func() {
 		startTime := time.Now()
 		var wg sync.WaitGroup
-		var successCount, errorCount int
-		var failedURLs []string
+		var successCount, errorCount, cancelledCount int
+		var failedURLs, cancelledURLs []string
 		var mu sync.Mutex // To protect shared summary variables
 
-		for _, u := range urls {
-			wg.Add(1)
-			go func(u string) {
-				defer wg.Done()
-
-				// URL Validation
-				isPublic, err := c.isPublicURL(u)
-				if err != nil {
-					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
-					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: fmt.Sprintf("URL validation failed: %v", err), IsSuccess: false}
-					return
-				}
-				if !isPublic {
-					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
-					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: "SSRF attack suspected: URL resolves to a non-public IP", IsSuccess: false}
-					return
-				}
-
-				content, err := c.processURL(u, selector)
-				if err != nil {
-					log.Printf("ERROR: Failed to process %s: %v", u, err)
-					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
-					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: err.Error(), IsSuccess: false}
-					return
-				}
-
-				// Fetch the document again to get the title and metadata
-				resp, err := c.Client.Get(u)
-				if err != nil {
-					log.Printf("ERROR: Failed to fetch URL for metadata %s: %v", u, err)
-					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
-					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: fmt.Sprintf("failed to fetch URL for metadata: %v", err), IsSuccess: false}
-					return
-				}
-				defer resp.Body.Close()
-
-				// Limit response body for metadata parsing as well
-				resp.Body = http.MaxBytesReader(nil, resp.Body, maxBodySize)
-				doc, err := goquery.NewDocumentFromReader(resp.Body)
-				if err != nil {
-					log.Printf("ERROR: Failed to parse HTML for metadata %s: %v", u, err)
-					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
-					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: fmt.Sprintf("failed to parse HTML for metadata: %v", err), IsSuccess: false}
-					return
-				}
+		workers := c.Workers
+		if workers <= 0 {
+			workers = defaultWorkers
+		}
+		maxPerHost := c.MaxPerHost
+		if maxPerHost <= 0 {
+			maxPerHost = defaultMaxPerHost
+		}
+		limiter := newHostLimiter(maxPerHost)
 
-				// Extract metadata
-				pageMetadata := c.getMetadata(doc, u)
-				pageMetadata["retrieved_at"] = time.Now().Format(time.RFC3339)
+		queue := make(chan string)
+		go func() {
+			for _, u := range urls {
+				queue <- u
+			}
+			close(queue)
+		}()
 
-				// Convert content to Markdown
-				markdownContent := c.htmlToMarkdown(content)
+		for i := 0; i < min(workers, len(urls)); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
 
-				// Marshal metadata to YAML
-				yamlBytes, err := yaml.Marshal(pageMetadata)
-				if err != nil {
-					log.Printf("ERROR: Failed to marshal YAML for %s: %v", u, err)
-					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
-					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: fmt.Sprintf("failed to marshal YAML: %v", err), IsSuccess: false}
-					return
-				}
+				for u := range queue {
+					result := c.processURL(ctx, limiter, u, selector, visit)
 
-				// Combine frontmatter and markdown content
-				var buf bytes.Buffer
-				buf.WriteString("---\n")
-				buf.Write(yamlBytes)
-				buf.WriteString("---\n\n")
-				buf.WriteString(markdownContent)
-				finalContent := buf.Bytes()
-				filename := c.getSanitizedTitle(doc, u) + ".md"
-
-				// Write the file to the configured output directory
-				filePath := filepath.Join(c.OutputDir, filename)
-				if err := os.WriteFile(filePath, finalContent, 0644); err != nil {
 					mu.Lock()
-					errorCount++
-					failedURLs = append(failedURLs, u)
+					switch {
+					case result.IsSuccess:
+						successCount++
+					case result.Cancelled:
+						cancelledCount++
+						cancelledURLs = append(cancelledURLs, u)
+					default:
+						errorCount++
+						failedURLs = append(failedURLs, u)
+					}
 					mu.Unlock()
-					resultsChan <- Result{URL: u, Error: fmt.Sprintf("failed to write file: %v", err), IsSuccess: false}
-					return
+					resultsChan <- result
 				}
-
-				mu.Lock()
-				successCount++
-				mu.Unlock()
-				resultsChan <- Result{
-					URL:       u,
-					FileName:  filename,
-					Content:   finalContent, // Keep for CLI compatibility for now
-					IsSuccess: true,
-				}
-			}(u)
+			}()
 		}
 
 		wg.Wait()
@@ -222,6 +286,8 @@ func (c *Converter) Convert(urls []string, selector string) (<-chan Result, <-ch
 			Successful:     successCount,
 			Failed:         errorCount,
 			FailedURLs:     failedURLs,
+			Cancelled:      cancelledCount,
+			CancelledURLs:  cancelledURLs,
 			ProcessingTime: time.Since(startTime).String(),
 			DownloadID:     c.DownloadID,
 		}
@@ -232,64 +298,244 @@ func (c *Converter) Convert(urls []string, selector string) (<-chan Result, <-ch
 	return resultsChan, summaryChan
 }
 
-// processURL fetches the HTML content at the given URL and extracts elements matching the provided selector.
-// On error or if no selection is found, returns a descriptive error including the URL and selector.
-func (c *Converter) processURL(urlStr string, selector string) (string, error) {
-	resp, err := c.Client.Get(urlStr)
+// processURL converts a single URL within the per-host limit and passes the
+// parsed page to visit. It runs on a worker goroutine, where a panic cannot be
+// recovered by the caller of ConvertContext and would crash the process, so a
+// panic while converting the page is reported as a failed result instead.
+func (c *Converter) processURL(ctx context.Context, limiter *hostLimiter, u, selector string, visit func(string, *goquery.Document)) (result Result) {
+	release, err := limiter.acquire(ctx, u)
+	if err != nil {
+		return cancelledResult(u, err)
+	}
+	defer release()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ERROR: Panic while converting %s: %v\n%s", u, r, debug.Stack())
+			result = Result{URL: u, Error: fmt.Sprintf("conversion panicked: %v", r), IsSuccess: false}
+		}
+	}()
+
+	result, doc := c.convertURL(ctx, u, selector)
+	if visit != nil && doc != nil {
+		visit(u, doc)
+	}
+	return result
+}
+
+// convertURL validates, fetches and converts a single URL, writing the resulting
+// file to the configured storage. The page is downloaded and parsed exactly
+// once; the same document is used for content selection, metadata and the filename.
+//
+// If ctx is cancelled before the file is written, the URL is reported as cancelled.
+// The parsed page is returned alongside the result, or nil if it could not be fetched.
+func (c *Converter) convertURL(ctx context.Context, u string, selector string) (Result, *goquery.Document) {
+	if err := ctx.Err(); err != nil {
+		return cancelledResult(u, err), nil
+	}
+
+	// The client refuses destinations blocked by the SSRF policy, including after redirects
+	doc, err := c.fetchDocument(ctx, u)
+	if err != nil {
+		if ctx.Err() != nil {
+			return cancelledResult(u, ctx.Err()), nil
+		}
+		if errors.Is(err, ErrNonPublicAddress) {
+			log.Printf("ERROR: Refused to fetch %s: %v", u, err)
+			return Result{URL: u, Error: fmt.Sprintf("SSRF attack suspected: %v", err), IsSuccess: false}, nil
+		}
+		log.Printf("ERROR: Failed to process %s: %v", u, err)
+		return Result{URL: u, Error: err.Error(), IsSuccess: false}, nil
+	}
+
+	profile := c.Options.Profiles.Match(u)
+	if profile != nil {
+		log.Printf("INFO: Using extraction profile %q for %s", profile.Name, u)
+	}
+
+	content, contentPath, err := c.extractContent(doc, u, c.extractionRules(selector, profile))
+	if err != nil {
+		log.Printf("ERROR: Failed to process %s: %v", u, err)
+		return Result{URL: u, Error: err.Error(), IsSuccess: false}, doc
+	}
+
+	var finalContent []byte
+	if c.Options.OutputFormat == FormatHTML {
+		finalContent = []byte(content)
+	} else {
+		finalContent, err = c.renderMarkdown(doc, u, content, profile)
+		if err != nil {
+			log.Printf("ERROR: Failed to render %s: %v", u, err)
+			return Result{URL: u, Error: err.Error(), IsSuccess: false}, doc
+		}
+	}
+
+	filename, err := c.fileName(doc, u, profile)
+	if err != nil {
+		log.Printf("ERROR: Failed to build filename for %s: %v", u, err)
+		return Result{URL: u, Error: err.Error(), IsSuccess: false}, doc
+	}
+
+	// Don't leave files behind for a conversion that has been abandoned
+	if err := ctx.Err(); err != nil {
+		return cancelledResult(u, err), doc
+	}
+
+	// Write the file to the configured storage
+	storage := c.Storage
+	if storage == nil {
+		storage = &FileStorage{Dir: c.OutputDir}
+	}
+	if err := storage.Put(ctx, filename, finalContent); err != nil {
+		return Result{URL: u, Error: fmt.Sprintf("failed to write file: %v", err), IsSuccess: false}, doc
+	}
+
+	return Result{
+		URL:         u,
+		FileName:    filename,
+		Content:     finalContent, // Keep for CLI compatibility for now
+		IsSuccess:   true,
+		ContentPath: contentPath,
+	}, doc
+}
+
+// renderMarkdown converts the selected HTML to Markdown and prefixes it with the
+// page metadata and custom fields as front matter in the configured format.
+func (c *Converter) renderMarkdown(doc *goquery.Document, u string, content string, profile *Profile) ([]byte, error) {
+	// Extract metadata
+	pageMetadata := c.getMetadata(doc, u, profile)
+	pageMetadata["retrieved_at"] = time.Now().Truncate(time.Second)
+
+	// Convert content to Markdown
+	markdownContent := c.htmlToMarkdown(content)
+
+	// Encode the metadata and custom fields
+	data := c.templateData(doc, u, profile)
+	data.Meta = pageMetadata
+	fields, err := c.frontMatterFields(pageMetadata, data)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch URL %s: %v", urlStr, err)
+		return nil, err
+	}
+	frontMatter, err := c.renderFrontMatter(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine frontmatter and markdown content
+	var buf bytes.Buffer
+	if len(frontMatter) > 0 {
+		buf.Write(frontMatter)
+		buf.WriteString("\n")
+	}
+	buf.WriteString(markdownContent)
+	return buf.Bytes(), nil
+}
+
+// fetchDocument downloads the page at the given URL and parses it into a goquery document.
+// Non-200 responses and bodies larger than maxBodySize are reported as errors.
+func (c *Converter) fetchDocument(ctx context.Context, urlStr string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %v", urlStr, err)
+	}
+
+	// Reject disallowed schemes, ports and literal addresses before connecting
+	if err := c.validator.CheckURL(req.URL); err != nil {
+		return nil, fmt.Errorf("failed to fetch URL %s: %w", urlStr, err)
+	}
+
+	c.Options.applyHeaders(req)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL %s: %w", urlStr, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch URL %s: HTTP status %d", urlStr, resp.StatusCode)
+		return nil, fmt.Errorf("failed to fetch URL %s: HTTP status %d", urlStr, resp.StatusCode)
 	}
 
-	// Limit response body to 5MB
-	resp.Body = http.MaxBytesReader(nil, resp.Body, maxBodySize)
+	// Limit response body to the configured size (5MB by default)
+	limit := c.Options.MaxBodySize
+	if limit <= 0 {
+		limit = maxBodySize
+	}
+	resp.Body = http.MaxBytesReader(nil, resp.Body, limit)
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read HTML for %s: %v", urlStr, err)
+		return nil, fmt.Errorf("failed to read HTML for %s: %v", urlStr, err)
 	}
+	return doc, nil
+}
 
-	content := doc.Find(selector)
-	if content.Length() == 0 {
-		return "", fmt.Errorf("could not find content in %s using selector '%s'", urlStr, selector)
+// cancelledResult builds the Result reported for a URL whose conversion was stopped by ctx.
+func cancelledResult(u string, err error) Result {
+	return Result{URL: u, Error: fmt.Sprintf("conversion cancelled: %v", err), IsSuccess: false, Cancelled: true}
+}
+
+// extractionRules returns the rules for one page: the converter's rules with
+// the given selector, overridden by the page's profile when there is one.
+func (c *Converter) extractionRules(selector string, profile *Profile) ExtractionRules {
+	rules := c.Options.Extraction
+	rules.Selector = selector
+	if profile == nil {
+		return rules
 	}
 
-	htmlContent, err := content.Html()
-	if err != nil {
-		return "", fmt.Errorf("failed to get HTML content for selector '%s': %v", selector, err)
+	if profile.Include != "" {
+		rules.Selector = profile.Include
 	}
-	return htmlContent, nil
+	rules.Exclude = append(slices.Clip(rules.Exclude), profile.Exclude...)
+	rules.KeepBoilerplate = rules.KeepBoilerplate || profile.KeepBoilerplate
+	return rules
 }
 
-// isPublicURL checks if a URL resolves to a public IP address to prevent SSRF attacks.
-// func (c *Converter) isPublicURL(urlStr string) (bool, error) {
-// 	parsedURL, err := url.Parse(urlStr)
-// 	if err != nil {
-// 		return false, err
-// 	}
-
-// 	ips, err := net.LookupIP(parsedURL.Hostname())
-// 	if err != nil {
-// 		return false, err
-// 	}
+// extractContent returns the HTML of the elements in doc matching the selector of the rules.
+// If no selection is found, returns a descriptive error including the URL and selector.
+// An empty selector selects the main content automatically; the selector of the
+// chosen element is returned as the content path. Boilerplate and excluded
+// elements are removed from the returned HTML.
+func (c *Converter) extractContent(doc *goquery.Document, urlStr string, rules ExtractionRules) (string, string, error) {
+	selector := rules.Selector
+	var content *goquery.Selection
+	var contentPath string
+	if selector == "" {
+		var err error
+		content, contentPath, err = extractAuto(doc)
+		if err != nil {
+			return "", "", fmt.Errorf("could not find content in %s: %v", urlStr, err)
+		}
+	} else {
+		content = doc.Find(selector)
+		if content.Length() == 0 {
+			return "", "", fmt.Errorf("could not find content in %s using selector '%s'", urlStr, selector)
+		}
+	}
 
-// 	for _, ip := range ips {
-// 		if ip.IsLoopback() || ip.IsLinkLocalMulticast() || ip.IsLinkLocalUnicast() || ip.IsPrivate() {
-// 			return false, nil // Found a non-public IP
-// 		}
-// 	}
+	htmlContent, err := cleanContent(content, rules).Html()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get HTML content for selector '%s': %v", selector, err)
+	}
+	return htmlContent, contentPath, nil
+}
 
-// 	return true, nil
-// }
+// pageTitle returns the page title, read from the profile's title element when
+// it has one and the element is present, and from the title tag otherwise.
+func pageTitle(doc *goquery.Document, profile *Profile) string {
+	if profile != nil && profile.Title != "" {
+		if title := (MetadataRule{Selector: profile.Title}).value(doc); title != "" {
+			return title
+		}
+	}
+	return strings.TrimSpace(doc.Find("title").Text())
+}
 
 // getSanitizedTitle extracts the title from the document or uses the fallback URL
 // to create a valid filename
-func (c *Converter) getSanitizedTitle(doc *goquery.Document, fallbackURL string) string {
-	title := strings.TrimSpace(doc.Find("title").Text())
+func (c *Converter) getSanitizedTitle(doc *goquery.Document, fallbackURL string, profile *Profile) string {
+	title := pageTitle(doc, profile)
 	if title == "" {
 		// Use the last part of the URL as fallback
 		parts := strings.Split(fallbackURL, "/")
@@ -306,103 +552,81 @@ func (c *Converter) getSanitizedTitle(doc *goquery.Document, fallbackURL string)
 	return SanitizeFilename(title)
 }
 
-// getMetadata extracts relevant metadata from the goquery document.
-func (c *Converter) getMetadata(doc *goquery.Document, url string) map[string]interface{} {
-	metadata := make(map[string]interface{})
+// templateData is the data available to the filename and front matter templates.
+type templateData struct {
+	Title string // Sanitized page title
+	Host  string
+	Slug  string                 // Last segment of the URL path
+	Meta  map[string]interface{} // Extracted front matter fields; nil for file names
+}
 
-	// Source URL
-	metadata["source"] = url
+// templateData returns the template data for a page, without Meta.
+func (c *Converter) templateData(doc *goquery.Document, u string, profile *Profile) templateData {
+	data := templateData{Title: c.getSanitizedTitle(doc, u, profile)}
+	if parsedURL, err := url.Parse(u); err == nil {
+		data.Host = parsedURL.Hostname()
+		data.Slug = path.Base(strings.TrimSuffix(parsedURL.Path, "/"))
+	}
+	return data
+}
 
-	// Title
-	title := strings.TrimSpace(doc.Find("title").Text())
-	if title != "" {
-		metadata["title"] = title
+// fileName builds the output file name for a page from the filename template,
+// falling back to the sanitized page title when no template is configured.
+// Pages that would get the same name are numbered, as in "title-2.md".
+func (c *Converter) fileName(doc *goquery.Document, u string, profile *Profile) (string, error) {
+	if c.filenameTemplate == nil {
+		return c.uniqueName(c.getSanitizedTitle(doc, u, profile)), nil
 	}
 
-	// Description from meta tag
-	doc.Find("meta[name='description']").Each(func(i int, s *goquery.Selection) {
-		if desc, exists := s.Attr("content"); exists {
-			metadata["description"] = desc
-		}
-	})
+	var b strings.Builder
+	if err := c.filenameTemplate.Execute(&b, c.templateData(doc, u, profile)); err != nil {
+		return "", fmt.Errorf("failed to render filename template: %v", err)
+	}
 
-	// Keywords from meta tag
-	doc.Find("meta[name='keywords']").Each(func(i int, s *goquery.Selection) {
-		if keywords, exists := s.Attr("content"); exists {
-			metadata["keywords"] = keywords
-		}
-	})
+	name := SanitizeFilename(b.String())
+	if name == "" {
+		name = "untitled"
+	}
+	return c.uniqueName(name), nil
+}
+
+// uniqueName prefixes base with FilePrefix and appends the file extension,
+// adding a counter if the name has already been given to another page by this
+// converter.
+func (c *Converter) uniqueName(base string) string {
+	c.namesMu.Lock()
+	defer c.namesMu.Unlock()
 
-	return metadata
+	if c.names == nil {
+		c.names = make(map[string]bool)
+	}
+	name := c.FilePrefix + base + c.Options.fileExtension()
+	for i := 2; c.names[name]; i++ {
+		name = fmt.Sprintf("%s%s-%d%s", c.FilePrefix, base, i, c.Options.fileExtension())
+	}
+	c.names[name] = true
+	return name
 }
 
-// htmlToMarkdown converts a given HTML string to Markdown.
-// This is a simplified conversion and might need a more robust library for complex HTML.
+// htmlToMarkdown converts a given HTML string to Markdown by walking the parsed
+// DOM, preserving headings, lists, tables, code, images, emphasis and quotes.
 func (c *Converter) htmlToMarkdown(htmlContent string) string {
-	// This is a simplified conversion. For robust conversion, a dedicated library like
-	// "github.com/JohannesKaufmann/html-to-markdown" would be used.
-	// For the purpose of this task, we'll implement basic conversions.
-
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
 	if err != nil {
 		log.Printf("ERROR: Failed to parse HTML for markdown conversion: %v", err)
 		return ""
 	}
 
-	var markdownBuilder strings.Builder
-
-	// Create a selection from the document
-	var selection *goquery.Selection
-	body := doc.Find("body")
-	if body.Length() > 0 {
-		selection = body
-	} else {
+	// Render from the body when present so head elements are never included.
+	selection := doc.Find("body")
+	if selection.Length() == 0 {
 		selection = doc.Selection
 	}
 
-	// Find all relevant elements and process them
-	selection.Find("h1, h2, h3, h4, h5, h6, p, a").Each(func(i int, s *goquery.Selection) {
-		tagName := goquery.NodeName(s)
-		text := strings.TrimSpace(s.Text())
-
-		if text == "" {
-			return
-		}
-
-		switch tagName {
-		case "h1":
-			markdownBuilder.WriteString("# " + text + "\n\n")
-		case "h2":
-			markdownBuilder.WriteString("## " + text + "\n\n")
-		case "h3":
-			markdownBuilder.WriteString("### " + text + "\n\n")
-		case "h4":
-			markdownBuilder.WriteString("#### " + text + "\n\n")
-		case "h5":
-			markdownBuilder.WriteString("##### " + text + "\n\n")
-		case "h6":
-			markdownBuilder.WriteString("###### " + text + "\n\n")
-		case "p":
-			markdownBuilder.WriteString(text + "\n\n")
-		case "a":
-			href, exists := s.Attr("href")
-			if exists {
-				markdownBuilder.WriteString(fmt.Sprintf("[%s](%s)", text, href))
-			} else {
-				markdownBuilder.WriteString(text)
-			}
-		}
-	})
-
-	// If no specific tags found, just use the text content
-	if markdownBuilder.Len() == 0 {
-		text := strings.TrimSpace(selection.Text())
-		if text != "" {
-			markdownBuilder.WriteString(text)
-		}
+	var renderer markdownRenderer
+	var blocks []string
+	for _, node := range selection.Nodes {
+		blocks = append(blocks, renderer.render(node))
 	}
-
-	// Clean up multiple newlines and trim overall whitespace
-	result := regexp.MustCompile(`\n\n+`).ReplaceAllString(markdownBuilder.String(), "\n\n")
-	return strings.TrimSpace(result)
+	return strings.TrimSpace(strings.Join(blocks, "\n\n"))
 }
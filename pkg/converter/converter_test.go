@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/ssrf"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// panicStorage panics when a page whose name contains "boom" is stored.
+type panicStorage struct {
+	*MemoryStorage
+}
+
+func (s panicStorage) Put(ctx context.Context, name string, data []byte) error {
+	if strings.Contains(name, "boom") {
+		panic("storage exploded")
+	}
+	return s.MemoryStorage.Put(ctx, name, data)
+}
+
+func TestConvertRecoversPanics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body><main><p>Hello</p></main></body></html>", strings.Trim(r.URL.Path, "/"))
+	}))
+	defer server.Close()
+
+	c, err := NewConverterWithStorage("", panicStorage{NewMemoryStorage()},
+		WithURLValidator(ssrf.AllowAddrPorts(ssrf.Default(), netip.MustParseAddrPort(server.Listener.Addr().String()))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{server.URL + "/fine", server.URL + "/boom", server.URL + "/also-fine"}
+	results, summaries := c.ConvertContext(context.Background(), urls, "")
+
+	failed := make(map[string]string)
+	for result := range results {
+		if !result.IsSuccess {
+			failed[result.URL] = result.Error
+		}
+	}
+	summary := <-summaries
+
+	if summary.Successful != 2 || summary.Failed != 1 {
+		t.Fatalf("summary = %d successful, %d failed, want 2 and 1", summary.Successful, summary.Failed)
+	}
+	if msg := failed[server.URL+"/boom"]; !strings.Contains(msg, "panicked") {
+		t.Fatalf("error for /boom = %q, want a panic report", msg)
+	}
+}
+
+func TestConvertSharedStorage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><head><title>Index</title></head><body><main><p>Hello</p></main></body></html>")
+	}))
+	defer server.Close()
+
+	// Two shards of a job convert pages with the same title into one storage
+	storage := NewMemoryStorage()
+	validator := ssrf.AllowAddrPorts(ssrf.Default(), netip.MustParseAddrPort(server.Listener.Addr().String()))
+	var names []string
+	for _, prefix := range []string{"1-", "2-"} {
+		c, err := NewConverterWithStorage("", storage, WithURLValidator(validator))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.FilePrefix = prefix
+
+		results, summaries := c.ConvertContext(context.Background(), []string{server.URL + "/a", server.URL + "/b"}, "")
+		for result := range results {
+			if !result.IsSuccess {
+				t.Fatalf("converting %s: %s", result.URL, result.Error)
+			}
+			names = append(names, result.FileName)
+		}
+		<-summaries
+	}
+
+	slices.Sort(names)
+	want := []string{"1-index-2.md", "1-index.md", "2-index-2.md", "2-index.md"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("file names = %v, want %v", names, want)
+	}
+	for _, name := range names {
+		if _, err := storage.Open(context.Background(), name); err != nil {
+			t.Errorf("file %s was not stored: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultMaxPages caps a crawl when CrawlOptions.MaxPages is not set.
+const defaultMaxPages = 100
+
+// CrawlOptions limit how far Crawl follows links from its start URL.
+type CrawlOptions struct {
+	// MaxDepth is the number of link hops followed from the start URL. Zero
+	// converts only the start URL.
+	MaxDepth int
+	// MaxPages is the maximum number of pages converted, including the start URL.
+	MaxPages int
+}
+
+// Crawl converts the page at start and the pages it links to, breadth first.
+// Only links on the same host and under the directory of the start URL are
+// followed; for https://example.com/docs/intro that is https://example.com/docs/.
+// Each page is fetched once, and Results and the Summary are reported as in
+// ConvertContext, with the Summary covering every converted page.
+func (c *Converter) Crawl(ctx context.Context, start string, selector string, opts CrawlOptions) (<-chan Result, <-chan Summary) {
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+
+	resultsChan := make(chan Result)
+	summaryChan := make(chan Summary)
+
+	go func() {
+		startTime := time.Now()
+		summary := Summary{DownloadID: c.DownloadID}
+
+		// An unparsable start URL is still converted so that it is reported as failed
+		scope, scopeErr := crawlScope(start)
+
+		seen := map[string]bool{start: true}
+		level := []string{start}
+		for depth := 0; len(level) > 0 && ctx.Err() == nil; depth++ {
+			var next []string
+			var mu sync.Mutex
+
+			var visit func(string, *goquery.Document)
+			if depth < opts.MaxDepth && scopeErr == nil {
+				visit = func(u string, doc *goquery.Document) {
+					links := pageLinks(doc, u, scope)
+
+					mu.Lock()
+					defer mu.Unlock()
+					for _, link := range links {
+						if len(seen) >= maxPages {
+							return
+						}
+						if !seen[link] {
+							seen[link] = true
+							next = append(next, link)
+						}
+					}
+				}
+			}
+
+			results, levelSummary := c.convertAll(ctx, level, selector, visit)
+			for result := range results {
+				resultsChan <- result
+			}
+			s := <-levelSummary
+
+			summary.TotalURLs += s.TotalURLs
+			summary.Successful += s.Successful
+			summary.Failed += s.Failed
+			summary.FailedURLs = append(summary.FailedURLs, s.FailedURLs...)
+			summary.Cancelled += s.Cancelled
+			summary.CancelledURLs = append(summary.CancelledURLs, s.CancelledURLs...)
+
+			level = next
+		}
+
+		close(resultsChan)
+
+		summary.ProcessingTime = time.Since(startTime).String()
+		summaryChan <- summary
+		close(summaryChan)
+	}()
+
+	return resultsChan, summaryChan
+}
+
+// crawlScope returns the URL whose host and directory bound a crawl from start.
+func crawlScope(start string) (*url.URL, error) {
+	u, err := url.Parse(start)
+	if err != nil {
+		return nil, err
+	}
+	scope := *u
+	scope.Path = "/"
+	if i := strings.LastIndex(u.Path, "/"); i >= 0 {
+		scope.Path = u.Path[:i+1]
+	}
+	scope.RawPath = ""
+	scope.RawQuery = ""
+	scope.Fragment = ""
+	return &scope, nil
+}
+
+// pageLinks returns the absolute URLs of the links on a page that fall within
+// scope, without fragments and in document order.
+func pageLinks(doc *goquery.Document, pageURL string, scope *url.URL) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host != scope.Host ||
+			!strings.HasPrefix(u.Path, scope.Path) {
+			return
+		}
+		links = append(links, u.String())
+	})
+	return links
+}
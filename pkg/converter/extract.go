@@ -0,0 +1,265 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Automatic extraction scores the containers of paragraph-like elements by the
+// amount of prose they hold, in the style of Mozilla's Readability, and picks
+// the container with the highest score after discounting link-heavy ones.
+
+// minParagraphLength is the shortest text that counts as a paragraph.
+const minParagraphLength = 25
+
+var (
+	// unlikelyRegex matches class and id values of page furniture.
+	unlikelyRegex = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|pager|pagination|popup|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental|tags|toolbar|widget`)
+	// maybeRegex rescues elements matching unlikelyRegex that may still hold the content.
+	maybeRegex = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	// positiveRegex and negativeRegex adjust the score of a container by its class and id.
+	positiveRegex = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story|prose|markdown|documentation`)
+	negativeRegex = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+
+	// simpleIdentRegex matches ids and class names usable in a selector without escaping.
+	simpleIdentRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+// scoredTags are the elements whose text is scored and credited to their ancestors.
+var scoredTags = map[string]bool{
+	"p": true, "pre": true, "td": true, "blockquote": true, "section": true, "h2": true, "h3": true, "dd": true,
+}
+
+// excludedTags are never part of the main content.
+var excludedTags = map[string]bool{
+	"nav": true, "aside": true, "footer": true, "header": true, "form": true, "menu": true, "button": true, "select": true,
+}
+
+// boilerplateSelector matches the elements removed from the selected content
+// unless ExtractionRules.KeepBoilerplate is set.
+const boilerplateSelector = `script, style, noscript, template, nav, aside, form, button, dialog, ` +
+	`[hidden], [aria-hidden="true"], [style*="display:none"], [style*="display: none"], ` +
+	`[style*="visibility:hidden"], [style*="visibility: hidden"]`
+
+// cleanContent returns a copy of content without boilerplate and without the
+// elements matching the exclude selectors of rules. The document itself is
+// left intact, since its title, metadata and links are still used after extraction.
+func cleanContent(content *goquery.Selection, rules ExtractionRules) *goquery.Selection {
+	cleaned := content.Clone()
+	if !rules.KeepBoilerplate {
+		cleaned.Find(boilerplateSelector).Remove()
+	}
+	for _, selector := range rules.Exclude {
+		cleaned.Find(selector).Remove()
+	}
+	return cleaned
+}
+
+// extractAuto finds the element most likely to hold the main content of a page
+// and returns it with a CSS selector that matches it.
+func extractAuto(doc *goquery.Document) (*goquery.Selection, string, error) {
+	body := doc.Find("body")
+	if body.Length() == 0 {
+		return nil, "", fmt.Errorf("document has no body")
+	}
+
+	if best := topCandidate(body.Get(0)); best != nil {
+		return doc.FindNodes(best), selectorPath(best), nil
+	}
+
+	// Pages without enough prose to score fall back to their semantic landmarks
+	for _, fallback := range []string{"article", "main", "[role=main]"} {
+		if sel := doc.Find(fallback); sel.Length() == 1 {
+			return sel, selectorPath(sel.Get(0)), nil
+		}
+	}
+	return body.First(), "body", nil
+}
+
+// topCandidate scores the containers of every paragraph under root and returns
+// the best one, or nil if the page has no paragraphs long enough to score.
+func topCandidate(root *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	addScore := func(n *html.Node, score float64) {
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if !isElement(c) || skipTags[c.Data] || excludedTags[c.Data] || isUnlikely(c) {
+				continue
+			}
+
+			if scoredTags[c.Data] {
+				text := strings.TrimSpace(whitespaceRegex.ReplaceAllString(visibleText(c), " "))
+				if len(text) >= minParagraphLength {
+					// One point per paragraph, one per comma and one per 100 characters, up to 3
+					score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text)/100), 3)
+					if parent := c.Parent; parent != nil && isElement(parent) {
+						addScore(parent, score)
+						if grandparent := parent.Parent; grandparent != nil && isElement(grandparent) {
+							addScore(grandparent, score/2)
+						}
+					}
+				}
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range candidates {
+		score := scores[n] * (1 - linkDensity(n))
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	return best
+}
+
+// initialScore is the score a container starts with, based on its tag and on
+// its class and id.
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.Data {
+	case "article":
+		score = 10
+	case "div", "main":
+		score = 5
+	case "pre", "td", "blockquote", "section":
+		score = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li":
+		score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score = -5
+	}
+
+	for _, value := range []string{attr(n, "class"), attr(n, "id")} {
+		if value == "" {
+			continue
+		}
+		if negativeRegex.MatchString(value) {
+			score -= 25
+		}
+		if positiveRegex.MatchString(value) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// isUnlikely reports whether an element is page furniture such as a sidebar,
+// menu or comment section, judging by its class, id and role.
+func isUnlikely(n *html.Node) bool {
+	if n.Data == "body" || n.Data == "article" || n.Data == "main" {
+		return false
+	}
+	switch attr(n, "role") {
+	case "navigation", "banner", "complementary", "contentinfo", "menu", "menubar", "dialog", "alertdialog":
+		return true
+	}
+	match := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyRegex.MatchString(match) && !maybeRegex.MatchString(match)
+}
+
+// linkDensity returns the fraction of an element's text that is link text.
+func linkDensity(n *html.Node) float64 {
+	textLength := len(strings.TrimSpace(visibleText(n)))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if isElement(c) && c.Data == "a" {
+				linkLength += len(strings.TrimSpace(visibleText(c)))
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linkLength) / float64(textLength)
+}
+
+// visibleText returns the text of n, leaving out scripts, styles and other
+// elements that are never rendered as text.
+func visibleText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if isElement(n) && skipTags[n.Data] {
+		return ""
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(visibleText(c))
+	}
+	return b.String()
+}
+
+// selectorPath returns a CSS selector that matches exactly n, such as
+// "#content > article.post" or "body > div:nth-of-type(2) > main". It stops at
+// the nearest ancestor with a usable id.
+func selectorPath(n *html.Node) string {
+	var steps []string
+	for ; n != nil && isElement(n); n = n.Parent {
+		if id := attr(n, "id"); simpleIdentRegex.MatchString(id) {
+			steps = append(steps, "#"+id)
+			break
+		}
+		if n.Data == "body" || n.Data == "html" {
+			steps = append(steps, n.Data)
+			break
+		}
+
+		step := n.Data
+		for _, class := range strings.Fields(attr(n, "class")) {
+			if simpleIdentRegex.MatchString(class) {
+				step += "." + class
+			}
+		}
+		if index, ambiguous := typeIndex(n); ambiguous {
+			step += fmt.Sprintf(":nth-of-type(%d)", index)
+		}
+		steps = append(steps, step)
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return strings.Join(steps, " > ")
+}
+
+// typeIndex returns the 1-based position of n among its siblings of the same
+// tag, and whether it has any such siblings.
+func typeIndex(n *html.Node) (int, bool) {
+	index, count := 0, 0
+	if n.Parent == nil {
+		return 1, false
+	}
+	for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if isElement(c) && c.Data == n.Data {
+			count++
+			if c == n {
+				index = count
+			}
+		}
+	}
+	return index, count > 1
+}
@@ -0,0 +1,260 @@
+package converter
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// frontMatterOrder is the order of the well-known fields in the front matter.
+// Other fields follow in alphabetical order, with nested objects such as
+// opengraph last.
+var frontMatterOrder = []string{
+	"title", "description", "authors", "published_at", "modified_at", "language",
+	"keywords", "section", "site_name", "image", "canonical_url", "source", "retrieved_at",
+}
+
+// frontMatterField is one entry of the front matter.
+type frontMatterField struct {
+	Key   string
+	Value interface{}
+}
+
+// parseFieldTemplates parses the string values of custom front matter fields
+// that contain template actions.
+func parseFieldTemplates(fields map[string]interface{}) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
+	for key, value := range fields {
+		if key == "" {
+			return nil, fmt.Errorf("front matter field names cannot be empty")
+		}
+		text, ok := value.(string)
+		if !ok || !strings.Contains(text, "{{") {
+			continue
+		}
+		tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for front matter field %q: %w", key, err)
+		}
+		templates[key] = tmpl
+	}
+	return templates, nil
+}
+
+// frontMatterFields applies the custom fields to the extracted metadata and
+// returns the result in output order.
+func (c *Converter) frontMatterFields(metadata map[string]interface{}, data templateData) ([]frontMatterField, error) {
+	fields := maps.Clone(metadata)
+	for key, value := range c.Options.FrontMatter.Fields {
+		if tmpl, ok := c.fieldTemplates[key]; ok {
+			var b strings.Builder
+			if err := tmpl.Execute(&b, data); err != nil {
+				return nil, fmt.Errorf("failed to render front matter field %q: %v", key, err)
+			}
+			// Missing map keys print as "<no value>" even with missingkey=zero
+			value = nil
+			if rendered := strings.TrimSpace(b.String()); rendered != "" && rendered != "<no value>" {
+				value = rendered
+			}
+		}
+		if value == nil {
+			delete(fields, key)
+			continue
+		}
+		fields[key] = value
+	}
+
+	keys := slices.Collect(maps.Keys(fields))
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(fieldRank(a, fields[a]), fieldRank(b, fields[b])), strings.Compare(a, b))
+	})
+
+	ordered := make([]frontMatterField, len(keys))
+	for i, key := range keys {
+		ordered[i] = frontMatterField{Key: key, Value: fields[key]}
+	}
+	return ordered, nil
+}
+
+// fieldRank returns the position group of a field: its index in
+// frontMatterOrder, then other fields, then nested objects.
+func fieldRank(key string, value interface{}) int {
+	if i := slices.Index(frontMatterOrder, key); i >= 0 {
+		return i
+	}
+	if _, ok := value.(map[string]interface{}); ok {
+		return len(frontMatterOrder) + 1
+	}
+	return len(frontMatterOrder)
+}
+
+// renderFrontMatter encodes the fields in the configured format, including
+// its delimiters. It returns nil for FrontMatterNone.
+func (c *Converter) renderFrontMatter(fields []frontMatterField) ([]byte, error) {
+	var buf bytes.Buffer
+	switch c.Options.FrontMatter.Format {
+	case FrontMatterNone:
+		return nil, nil
+
+	case FrontMatterTOML:
+		buf.WriteString("+++\n")
+		if err := writeTOML(&buf, fields); err != nil {
+			return nil, fmt.Errorf("failed to encode TOML: %v", err)
+		}
+		buf.WriteString("+++\n")
+
+	case FrontMatterJSON:
+		buf.WriteString("{\n")
+		for i, field := range fields {
+			key, _ := json.Marshal(field.Key)
+			value, err := json.MarshalIndent(field.Value, "  ", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode JSON field %q: %v", field.Key, err)
+			}
+			fmt.Fprintf(&buf, "  %s: %s", key, value)
+			if i < len(fields)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("}\n")
+
+	default:
+		items := make(yaml.MapSlice, len(fields))
+		for i, field := range fields {
+			items[i] = yaml.MapItem{Key: field.Key, Value: field.Value}
+		}
+		yamlBytes, err := yaml.Marshal(items)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal YAML: %v", err)
+		}
+		buf.WriteString("---\n")
+		buf.Write(yamlBytes)
+		buf.WriteString("---\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTOML writes fields as TOML key/value pairs. Nested objects become
+// tables, which is only valid because frontMatterFields orders them last.
+func writeTOML(buf *bytes.Buffer, fields []frontMatterField) error {
+	for _, field := range fields {
+		if table, ok := field.Value.(map[string]interface{}); ok {
+			fmt.Fprintf(buf, "\n[%s]\n", tomlKey(field.Key))
+			for _, key := range slices.Sorted(maps.Keys(table)) {
+				value, err := tomlValue(table[key])
+				if err != nil {
+					return fmt.Errorf("field %s.%s: %v", field.Key, key, err)
+				}
+				fmt.Fprintf(buf, "%s = %s\n", tomlKey(key), value)
+			}
+			continue
+		}
+
+		value, err := tomlValue(field.Value)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.Key, err)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(field.Key), value)
+	}
+	return nil
+}
+
+// tomlValue encodes a single value, using inline tables for nested objects.
+func tomlValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return tomlString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		// Numbers decoded from JSON are float64; keep whole numbers as integers
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10), nil
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []string:
+		items := make([]string, len(v))
+		for i, s := range v {
+			items[i] = tomlString(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			value, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = value
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		var items []string
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			value, err := tomlValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(key)+" = "+value)
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	case nil:
+		return "", fmt.Errorf("TOML has no null value")
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// tomlKey returns key as a bare key if possible and as a quoted key otherwise.
+func tomlKey(key string) string {
+	for _, r := range key {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(key)
+		}
+	}
+	return key
+}
+
+// tomlString encodes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// hostLimiter caps the number of in-flight requests per host.
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	slots map[string]chan struct{}
+}
+
+// newHostLimiter creates a hostLimiter allowing up to limit concurrent requests per host.
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// acquire blocks until a slot for the URL's host is free and returns a function
+// that releases it. It gives up and returns ctx's error if ctx is cancelled first.
+func (l *hostLimiter) acquire(ctx context.Context, urlStr string) (func(), error) {
+	sem := l.semaphore(hostKey(urlStr))
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// semaphore returns the slot channel for the given host, creating it on first use.
+func (l *hostLimiter) semaphore(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.slots[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.slots[host] = sem
+	}
+	return sem
+}
+
+// hostKey normalises the host of a URL for use as a limiter key. Unparseable
+// URLs share a single key; they fail validation before any request is made.
+func hostKey(urlStr string) string {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsedURL.Hostname())
+}
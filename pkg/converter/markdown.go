@@ -0,0 +1,527 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockTags are elements that start a new Markdown block when encountered.
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"center": true, "dd": true, "details": true, "dialog": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hgroup": true,
+	"hr": true, "html": true, "li": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "ul": true,
+}
+
+// skipTags are elements whose content never belongs in the Markdown output.
+var skipTags = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"iframe": true, "object": true, "embed": true, "svg": true, "canvas": true,
+}
+
+// maxEntityLength is the length of the longest HTML entity reference,
+// "&CounterClockwiseContourIntegral;".
+const maxEntityLength = 33
+
+var (
+	whitespaceRegex = regexp.MustCompile(`[ \t\n\r\f]+`)
+	// blockStartRegex matches text at the start of a line that would open a
+	// heading, quote, list or code fence, form a thematic break, or underline
+	// the line before it as a setext heading.
+	blockStartRegex = regexp.MustCompile(`^(#{1,6}( |$)|>|[-+*]( |$)|\d+[.)]( |$)|~~~|(- *){3,}|(\* *){3,}|(_ *){3,}|={3,}|(=+|-+) *$)`)
+	languageRegex   = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#.-]+)`)
+	hrefEscaper     = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+	entityRegex     = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+)
+
+// markdownRenderer walks an HTML node tree and renders it as CommonMark with
+// GitHub-flavoured tables and strikethrough.
+type markdownRenderer struct{}
+
+// render converts the node and all of its descendants to Markdown.
+func (r *markdownRenderer) render(n *html.Node) string {
+	return strings.Join(r.blocks(n), "\n\n")
+}
+
+// blocks renders the children of n as a sequence of Markdown blocks. Runs of
+// consecutive inline children are gathered into a single paragraph.
+func (r *markdownRenderer) blocks(n *html.Node) []string {
+	var out []string
+	var pending strings.Builder
+
+	flush := func() {
+		if p := cleanParagraph(pending.String()); p != "" {
+			out = append(out, p)
+		}
+		pending.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isElement(c) && blockTags[c.Data] {
+			flush()
+			out = append(out, r.block(c)...)
+			continue
+		}
+		appendInline(&pending, r.inline(c))
+	}
+	flush()
+
+	return out
+}
+
+// block renders a single block-level element.
+func (r *markdownRenderer) block(n *html.Node) []string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.Join(strings.Fields(r.inlineChildren(n)), " ")
+		if text == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		return []string{strings.Repeat("#", level) + " " + text}
+	case "p", "dt", "dd", "figcaption", "summary", "address":
+		if p := cleanParagraph(r.inlineChildren(n)); p != "" {
+			return []string{p}
+		}
+		return nil
+	case "hr":
+		return []string{"---"}
+	case "pre":
+		return []string{r.codeBlock(n)}
+	case "blockquote":
+		return nonEmpty(prefixLines(strings.Join(r.blocks(n), "\n\n"), "> ", ">"))
+	case "ul", "ol":
+		return nonEmpty(r.list(n))
+	case "table":
+		return r.table(n)
+	default:
+		// Generic containers (div, section, article, ...) are transparent.
+		return r.blocks(n)
+	}
+}
+
+// inline renders a node in an inline (paragraph) context.
+func (r *markdownRenderer) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(whitespaceRegex.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skipTags[n.Data] {
+		return ""
+	}
+
+	switch n.Data {
+	case "br":
+		return "  \n"
+	case "strong", "b":
+		return wrapInline(r.inlineChildren(n), "**", "**")
+	case "em", "i", "cite", "dfn":
+		return wrapInline(r.inlineChildren(n), "*", "*")
+	case "del", "s", "strike":
+		return wrapInline(r.inlineChildren(n), "~~", "~~")
+	case "code", "kbd", "samp", "tt", "var":
+		return codeSpan(whitespaceRegex.ReplaceAllString(textContent(n), " "))
+	case "a":
+		return r.link(n)
+	case "img":
+		return image(n)
+	}
+
+	if blockTags[n.Data] {
+		// A block nested inside inline content still separates words.
+		return " " + r.inlineChildren(n) + " "
+	}
+	return r.inlineChildren(n)
+}
+
+// inlineChildren renders all children of n in an inline context.
+func (r *markdownRenderer) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		appendInline(&b, r.inline(c))
+	}
+	return b.String()
+}
+
+// link renders an anchor as an inline Markdown link.
+func (r *markdownRenderer) link(n *html.Node) string {
+	content := r.inlineChildren(n)
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return content
+	}
+
+	text := strings.TrimSpace(content)
+	if text == "" {
+		text = escapeMarkdown(href)
+	}
+
+	dest := hrefEscaper.Replace(href)
+	if title := attr(n, "title"); title != "" {
+		dest += " " + strconv.Quote(title)
+	}
+	lead, trail := edgeSpaces(content)
+	return lead + "[" + text + "](" + dest + ")" + trail
+}
+
+// image renders an img element as an inline Markdown image.
+func image(n *html.Node) string {
+	src := strings.TrimSpace(attr(n, "src"))
+	if src == "" {
+		src = strings.TrimSpace(attr(n, "data-src"))
+	}
+	if src == "" {
+		return ""
+	}
+
+	alt := escapeMarkdown(strings.Join(strings.Fields(attr(n, "alt")), " "))
+	dest := hrefEscaper.Replace(src)
+	if title := attr(n, "title"); title != "" {
+		dest += " " + strconv.Quote(title)
+	}
+	return fmt.Sprintf("![%s](%s)", alt, dest)
+}
+
+// codeBlock renders a pre element as a fenced code block, picking up the
+// language from a "language-*" or "lang-*" class on the pre or its code child.
+func (r *markdownRenderer) codeBlock(n *html.Node) string {
+	lang := codeLanguage(n)
+	for c := n.FirstChild; c != nil && lang == ""; c = c.NextSibling {
+		if isElement(c) && c.Data == "code" {
+			lang = codeLanguage(c)
+		}
+	}
+
+	code := strings.TrimPrefix(preText(n), "\n")
+	code = strings.TrimRight(code, "\n")
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// list renders an ordered or unordered list. Nested lists are indented under
+// the item that contains them.
+func (r *markdownRenderer) list(n *html.Node) string {
+	ordered := n.Data == "ol"
+	index := 1
+	if start, err := strconv.Atoi(attr(n, "start")); ordered && err == nil {
+		index = start
+	}
+
+	var items []string
+	width := 2
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isElement(c) {
+			continue
+		}
+
+		switch c.Data {
+		case "li":
+			marker := "- "
+			if ordered {
+				marker = strconv.Itoa(index) + ". "
+				index++
+			}
+			width = len(marker)
+			items = append(items, marker+indentLines(r.listItem(c), width))
+		case "ul", "ol":
+			// Invalid but common: a nested list placed directly inside a list.
+			nested := indentLines(r.list(c), width)
+			if len(items) == 0 {
+				items = append(items, nested)
+			} else {
+				items[len(items)-1] += "\n" + strings.Repeat(" ", width) + nested
+			}
+		}
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// listItem renders the content of a single li. Items containing paragraphs are
+// rendered loose (blank line between blocks); all others are kept tight.
+func (r *markdownRenderer) listItem(n *html.Node) string {
+	sep := "\n"
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isElement(c) && c.Data == "p" {
+			sep = "\n\n"
+			break
+		}
+	}
+	return strings.Join(r.blocks(n), sep)
+}
+
+// table renders a table as a GitHub-flavoured Markdown table. The first row is
+// always used as the header row, since GFM requires one.
+func (r *markdownRenderer) table(n *html.Node) []string {
+	var out []string
+	var rows [][]string
+
+	var collect func(*html.Node)
+	collect = func(parent *html.Node) {
+		for c := parent.FirstChild; c != nil; c = c.NextSibling {
+			if !isElement(c) {
+				continue
+			}
+			switch c.Data {
+			case "caption":
+				if p := cleanParagraph(r.inlineChildren(c)); p != "" {
+					out = append(out, p)
+				}
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			case "tr":
+				rows = append(rows, r.tableRow(c))
+			}
+		}
+	}
+	collect(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return out
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+
+	return append(out, strings.Join(lines, "\n"))
+}
+
+// tableRow renders the cells of a single tr.
+func (r *markdownRenderer) tableRow(n *html.Node) []string {
+	var cells []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isElement(c) || (c.Data != "td" && c.Data != "th") {
+			continue
+		}
+		cell := strings.ReplaceAll(r.inlineChildren(c), "  \n", "<br>")
+		cell = strings.Join(strings.Fields(cell), " ")
+		cells = append(cells, strings.ReplaceAll(cell, "|", `\|`))
+	}
+	return cells
+}
+
+// appendInline appends inline content to b, collapsing the whitespace at the
+// seam so that adjacent nodes never produce double spaces. Leading space is
+// kept when b is empty, since it may separate an inline element from the word
+// before it; paragraphs and headings trim it when the block is finished.
+func appendInline(b *strings.Builder, s string) {
+	if s == "" {
+		return
+	}
+	current := b.String()
+	if strings.HasSuffix(current, " ") || strings.HasSuffix(current, "\n") {
+		s = strings.TrimLeft(s, " ")
+	}
+	b.WriteString(s)
+}
+
+// wrapInline surrounds the trimmed content with the given markers, keeping any
+// leading or trailing space outside of them as Markdown requires.
+func wrapInline(s, open, close string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+
+	lead, trail := edgeSpaces(s)
+	return lead + open + trimmed + close + trail
+}
+
+// edgeSpaces reports the single spaces, if any, at either end of s.
+func edgeSpaces(s string) (lead, trail string) {
+	if strings.HasPrefix(s, " ") {
+		lead = " "
+	}
+	if strings.HasSuffix(s, " ") {
+		trail = " "
+	}
+	return lead, trail
+}
+
+// codeSpan wraps text in enough backticks that it cannot close early.
+func codeSpan(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return s
+	}
+
+	longest, run := 0, 0
+	for _, ch := range s {
+		if ch == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// escapeMarkdown backslash-escapes characters in text that would otherwise be
+// interpreted as Markdown syntax or inline HTML. Underscores inside words are
+// left alone, since CommonMark does not treat them as emphasis, and so is an
+// ampersand that does not start an entity reference.
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, ch := range runes {
+		switch ch {
+		case '\\', '*', '`', '[', ']', '<':
+			b.WriteRune('\\')
+		case '&':
+			if entityRegex.MatchString(string(runes[i:min(len(runes), i+maxEntityLength)])) {
+				b.WriteRune('\\')
+			}
+		case '_':
+			if i == 0 || i == len(runes)-1 || !isWordRune(runes[i-1]) || !isWordRune(runes[i+1]) {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// cleanParagraph trims a rendered paragraph and escapes a leading character
+// that would otherwise turn a line into a heading, quote, list, code fence or
+// thematic break, or make a setext heading of the line above. For an ordered
+// list marker the delimiter is escaped ("1\."), since CommonMark does not
+// treat a backslash before a digit as an escape.
+func cleanParagraph(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			line = strings.TrimLeft(line, " ")
+		} else {
+			line = strings.TrimSpace(line)
+		}
+		if blockStartRegex.MatchString(line) {
+			digits := strings.IndexFunc(line, func(ch rune) bool { return ch < '0' || ch > '9' })
+			line = line[:digits] + `\` + line[digits:]
+		}
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// codeLanguage returns the language named by a node's class attribute, if any.
+func codeLanguage(n *html.Node) string {
+	if m := languageRegex.FindStringSubmatch(attr(n, "class")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// indentLines indents every line after the first by width spaces.
+func indentLines(s string, width int) string {
+	pad := strings.Repeat(" ", width)
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = pad + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixLines prefixes every line of s, using emptyPrefix for blank lines.
+func prefixLines(s, prefix, emptyPrefix string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nonEmpty wraps s in a slice, or returns nil if s is empty.
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// textContent returns the concatenated text of n and its descendants.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// preText returns the text of a pre element, with a line break for every br
+// element, which highlighters and CMS editors often use instead of newlines.
+func preText(n *html.Node) string {
+	switch {
+	case n.Type == html.TextNode:
+		return n.Data
+	case isElement(n) && n.Data == "br":
+		return "\n"
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(preText(c))
+	}
+	return b.String()
+}
+
+// attr returns the value of the named attribute, or "" if it is absent.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isElement(n *html.Node) bool {
+	return n.Type == html.ElementNode
+}
+
+func isWordRune(ch rune) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
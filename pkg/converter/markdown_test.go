@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		// Inline edge spaces
+		{"space before emphasis", `<p>a <em>b</em> c</p>`, `a *b* c`},
+		{"space inside emphasis", `<p>a<em> b </em>c</p>`, `a *b* c`},
+		{"leading space in nested inline", `<p>x<strong><em> y</em></strong>z</p>`, `x ***y***z`},
+		{"adjacent inline elements", `<p><em>a</em> <strong>b</strong></p>`, `*a* **b**`},
+		{"space inside link", `<p>see<a href="/x"> here </a>now</p>`, `see [here](/x) now`},
+		{"space after code", `<p>run <code>go test</code> now</p>`, "run `go test` now"},
+
+		// Escaping of inline syntax
+		{"inline markers", `<p>*a* _b_ [c] ` + "`d`" + `</p>`, "\\*a\\* \\_b\\_ \\[c\\] \\`d\\`"},
+		{"intraword underscore", `<p>snake_case_name</p>`, `snake_case_name`},
+		{"html and entities", `<p>&lt;tag&gt; &amp;amp; &amp; b</p>`, `\<tag> \&amp; & b`},
+
+		// Escaping of block syntax at the start of a line
+		{"heading", `<p># not a heading</p>`, `\# not a heading`},
+		{"quote", `<p>&gt; not a quote</p>`, `\> not a quote`},
+		{"bullet", `<p>- not a list</p>`, `\- not a list`},
+		{"plus bullet", `<p>+ not a list</p>`, `\+ not a list`},
+		{"ordered", `<p>1. not a list</p>`, `1\. not a list`},
+		{"ordered paren", `<p>12) not a list</p>`, `12\) not a list`},
+		{"number in text", `<p>1.5 million</p>`, `1.5 million`},
+		{"tilde fence", `<p>~~~</p><p>after</p>`, "\\~~~\n\nafter"},
+		{"dash break", `<p>---</p>`, `\---`},
+		{"spaced dash break", `<p>- - -</p>`, `\- - -`},
+		{"star break", `<p>***</p>`, `\*\*\*`},
+		{"underscore break", `<p>___</p>`, `\__\_`},
+		{"setext equals", `<p>Title<br>===</p>`, "Title  \n\\==="},
+		{"setext dash", `<p>Title<br>--</p>`, "Title  \n\\--"},
+		{"line break before list marker", `<p>a<br>- b</p>`, "a  \n\\- b"},
+
+		// Tables
+		{
+			"table with pipes",
+			`<table><tr><th>op</th><th>meaning</th></tr><tr><td>a|b</td><td>x || y</td></tr></table>`,
+			"| op | meaning |\n| --- | --- |\n| a\\|b | x \\|\\| y |",
+		},
+		{
+			"table with ragged rows",
+			`<table><thead><tr><th>a</th><th>b</th></tr></thead><tbody><tr><td>1</td></tr></tbody></table>`,
+			"| a | b |\n| --- | --- |\n| 1 |  |",
+		},
+
+		// Code blocks
+		{"pre", "<pre><code class=\"language-go\">x := 1\ny := 2\n</code></pre>", "```go\nx := 1\ny := 2\n```"},
+		{"pre with br", `<pre><code>line one<br>line two<br/><span>line three</span></code></pre>`, "```\nline one\nline two\nline three\n```"},
+		{"pre with fence", "<pre>```\ncode\n```</pre>", "````\n```\ncode\n```\n````"},
+
+		// Lists
+		{"list", `<ul><li>a</li><li>b</li></ul>`, "- a\n- b"},
+		{"ordered start", `<ol start="3"><li>a</li><li>b</li></ol>`, "3. a\n4. b"},
+		{
+			"nested list",
+			`<ul><li>a<ul><li>b<ol><li>c</li></ol></li></ul></li><li>d</li></ul>`,
+			"- a\n  - b\n    1. c\n- d",
+		},
+		{"list directly in list", `<ol><li>a</li><ul><li>b</li></ul></ol>`, "1. a\n   - b"},
+		{"loose item", `<ul><li><p>a</p><p>b</p></li></ul>`, "- a\n\n  b"},
+		{"escaped item", `<ul><li>- a</li></ul>`, `- \- a`},
+	}
+	c := &Converter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.htmlToMarkdown(tt.html); got != tt.want {
+				t.Errorf("htmlToMarkdown(%s)\ngot:\n%s\nwant:\n%s", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeMarkdownAmpersands(t *testing.T) {
+	// Only ampersands that start an entity reference are escaped, and each
+	// is matched against a bounded window rather than the rest of the text.
+	s := strings.Repeat("a & b &amp; ", 20000)
+	want := strings.Repeat(`a & b \&amp; `, 20000)
+	if got := escapeMarkdown(s); got != want {
+		t.Fatalf("escapeMarkdown escaped %d bytes, want %d", len(got), len(want))
+	}
+
+	long := "&" + strings.Repeat("a", maxEntityLength) + ";"
+	if got := escapeMarkdown(long); got != long {
+		t.Errorf("escapeMarkdown(%q) = %q, want it unchanged", long, got)
+	}
+}
@@ -0,0 +1,347 @@
+package converter
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata is gathered from the title tag, the meta tags (including OpenGraph
+// and Twitter cards), the canonical link, the lang attribute and the first
+// schema.org Article found in the page's JSON-LD. Dates are emitted as
+// time.Time and lists as []string, so they are written as typed YAML values.
+
+// dateLayouts are the date formats accepted for published and modified times.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// getMetadata extracts the front matter fields of a page. Fields read by the
+// metadata rules of the profile are added last and take precedence.
+func (c *Converter) getMetadata(doc *goquery.Document, u string, profile *Profile) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	meta := readMetaTags(doc)
+	article := findArticle(doc)
+
+	// Source URL
+	metadata["source"] = u
+
+	// Title and description, preferring the page's own tags over social cards
+	setFirst(metadata, "title", pageTitle(doc, profile), meta.first("og:title", "twitter:title"), article.text("headline"))
+	setFirst(metadata, "description", meta.first("description", "og:description", "twitter:description"), article.text("description"))
+	setFirst(metadata, "site_name", meta.first("og:site_name"), article.name("publisher"))
+	setFirst(metadata, "section", meta.first("article:section"), article.text("articleSection"))
+
+	// URLs, resolved against the page URL
+	canonical, _ := doc.Find("link[rel~=canonical]").Attr("href")
+	setFirst(metadata, "canonical_url", resolveURL(u, canonical), resolveURL(u, meta.first("og:url")), resolveURL(u, article.text("url")))
+	setFirst(metadata, "image", resolveURL(u, meta.first("og:image", "twitter:image")), resolveURL(u, article.image()))
+
+	// Language
+	lang, _ := doc.Find("html").Attr("lang")
+	setFirst(metadata, "language", strings.TrimSpace(lang), meta.first("content-language"), article.text("inLanguage"))
+
+	// Authors and dates, preferring structured data
+	var authors []string
+	authors = append(authors, article.names("author")...)
+	authors = append(authors, meta["author"]...)
+	for _, author := range meta["article:author"] {
+		// OpenGraph allows a profile URL here, which is not a name
+		if !strings.Contains(author, "://") {
+			authors = append(authors, author)
+		}
+	}
+	setList(metadata, "authors", authors)
+
+	setDate(metadata, "published_at", article.text("datePublished"), meta.first("article:published_time", "datepublished", "date", "dc.date", "dc.date.issued", "dcterms.created"))
+	setDate(metadata, "modified_at", article.text("dateModified"), meta.first("article:modified_time", "og:updated_time", "datemodified", "dcterms.modified", "last-modified"))
+
+	// Keywords and tags
+	var keywords []string
+	for _, value := range meta["keywords"] {
+		keywords = append(keywords, strings.Split(value, ",")...)
+	}
+	keywords = append(keywords, meta["article:tag"]...)
+	keywords = append(keywords, article.list("keywords")...)
+	setList(metadata, "keywords", keywords)
+
+	// Raw OpenGraph and Twitter card properties
+	if og := meta.prefixed("og:"); len(og) > 0 {
+		metadata["opengraph"] = og
+	}
+	if twitter := meta.prefixed("twitter:"); len(twitter) > 0 {
+		metadata["twitter"] = twitter
+	}
+
+	// Fields defined by the profile
+	if profile != nil {
+		for field, rule := range profile.Metadata {
+			if value := rule.value(doc); value != "" {
+				metadata[field] = value
+			}
+		}
+	}
+
+	return metadata
+}
+
+// metaTags holds the content of the page's meta tags by lower-cased name,
+// property, itemprop or http-equiv, in document order.
+type metaTags map[string][]string
+
+// readMetaTags collects the non-empty meta tags of doc.
+func readMetaTags(doc *goquery.Document) metaTags {
+	tags := make(metaTags)
+	doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		content := strings.TrimSpace(s.AttrOr("content", ""))
+		if content == "" {
+			return
+		}
+		for _, attr := range []string{"name", "property", "itemprop", "http-equiv"} {
+			if key := strings.ToLower(strings.TrimSpace(s.AttrOr(attr, ""))); key != "" {
+				tags[key] = append(tags[key], content)
+			}
+		}
+	})
+	return tags
+}
+
+// first returns the first value of the first key present.
+func (m metaTags) first(keys ...string) string {
+	for _, key := range keys {
+		if values := m[key]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// prefixed returns the tags starting with prefix, keyed without it. Repeated
+// tags, such as several og:image tags, become lists.
+func (m metaTags) prefixed(prefix string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for key, values := range m {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" {
+			continue
+		}
+		if len(values) == 1 {
+			fields[name] = values[0]
+		} else {
+			fields[name] = values
+		}
+	}
+	return fields
+}
+
+// ldObject is a decoded JSON-LD object. A nil ldObject has no fields.
+type ldObject map[string]interface{}
+
+// findArticle returns the first schema.org Article, or one of its subtypes
+// such as BlogPosting or TechArticle, in the page's JSON-LD scripts.
+func findArticle(doc *goquery.Document) ldObject {
+	var article ldObject
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true // Malformed blocks are common and are skipped
+		}
+		article = findArticleIn(data)
+		return article == nil
+	})
+	return article
+}
+
+// findArticleIn searches a decoded JSON-LD value, including arrays and @graph
+// lists, for an Article.
+func findArticleIn(v interface{}) ldObject {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if article := findArticleIn(item); article != nil {
+				return article
+			}
+		}
+	case map[string]interface{}:
+		if isArticleType(v["@type"]) {
+			return v
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findArticleIn(graph)
+		}
+	}
+	return nil
+}
+
+// isArticleType reports whether a JSON-LD @type, a string or a list of
+// strings, names an Article or a subtype of it.
+func isArticleType(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.HasSuffix(v, "Article") || strings.HasSuffix(v, "Posting") || v == "Report"
+	case []interface{}:
+		for _, t := range v {
+			if isArticleType(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// text returns a string field, or the first string of a list field.
+func (o ldObject) text(key string) string {
+	switch v := o[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+// name returns the name of a Person or Organization field.
+func (o ldObject) name(key string) string {
+	if names := o.names(key); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// names returns the names of a field holding a name, a Person or
+// Organization, or a list of them.
+func (o ldObject) names(key string) []string {
+	var names []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			names = append(names, v)
+		case map[string]interface{}:
+			collect(v["name"])
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+	collect(o[key])
+	return names
+}
+
+// list returns a field holding a list of strings or a comma-separated string.
+func (o ldObject) list(key string) []string {
+	switch v := o[key].(type) {
+	case string:
+		return strings.Split(v, ",")
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// image returns the URL of the image field, which may be a URL, an
+// ImageObject or a list of either.
+func (o ldObject) image() string {
+	switch v := o["image"].(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		return ldObject(v).text("url")
+	case []interface{}:
+		if len(v) > 0 {
+			return ldObject{"image": v[0]}.image()
+		}
+	}
+	return ""
+}
+
+// setFirst sets field to the first non-empty value.
+func setFirst(metadata map[string]interface{}, field string, values ...string) {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			metadata[field] = value
+			return
+		}
+	}
+}
+
+// setDate sets field to the first value that parses as a date.
+func setDate(metadata map[string]interface{}, field string, values ...string) {
+	for _, value := range values {
+		if t, ok := parseDate(value); ok {
+			metadata[field] = t
+			return
+		}
+	}
+}
+
+// setList sets field to the non-empty values, without duplicates, if there are any.
+func setList(metadata map[string]interface{}, field string, values []string) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[strings.ToLower(value)] {
+			continue
+		}
+		seen[strings.ToLower(value)] = true
+		list = append(list, value)
+	}
+	if len(list) > 0 {
+		metadata[field] = list
+	}
+}
+
+// parseDate parses a date in one of dateLayouts. Dates without a time zone are
+// taken to be UTC.
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// resolveURL resolves ref against the page URL base, returning "" for an empty
+// or invalid reference.
+func resolveURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
@@ -0,0 +1,417 @@
+package converter
+
+import (
+	"doc-converter-oci-serverless/pkg/ssrf"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// Supported values for ConversionOptions.OutputFormat.
+const (
+	FormatMarkdown = "markdown" // Markdown with front matter (default)
+	FormatHTML     = "html"     // The selected HTML fragment, unconverted
+)
+
+// Supported values for FrontMatterOptions.Format.
+const (
+	FrontMatterYAML = "yaml" // Between "---" lines, as used by Jekyll, Docusaurus and Hugo (default)
+	FrontMatterTOML = "toml" // Between "+++" lines, as used by Hugo
+	FrontMatterJSON = "json" // A JSON object at the top of the file, as supported by Hugo
+	FrontMatterNone = "none" // No front matter
+)
+
+// Upper limits accepted by ConversionOptions.Validate. They keep a single job
+// from exhausting the memory and time of a function invocation, and keep the
+// per-host limit low enough to avoid getting banned.
+const (
+	maxTimeout       = time.Minute
+	maxBodySizeLimit = 50 * 1024 * 1024 // 50MB
+	maxWorkers       = 32
+	maxPerHostLimit  = 8
+)
+
+const (
+	defaultUserAgent        = "doc-converter/1.0 (+https://github.com/apigban/doc-converter-oci-serverless)"
+	defaultFilenameTemplate = "{{.Title}}"
+)
+
+// Duration is a time.Duration that is encoded in JSON as a Go duration string
+// such as "5s" or "1m30s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Plain numbers are accepted as seconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", string(b))
+	}
+	return nil
+}
+
+// ExtractionRules control which part of a page is converted.
+type ExtractionRules struct {
+	// Selector is the CSS selector for the main content. It is used when
+	// Convert is called with an empty selector.
+	Selector string `json:"selector,omitempty"`
+	// Exclude lists CSS selectors for elements removed from the selected
+	// content, such as cookie banners or "edit this page" links.
+	Exclude []string `json:"exclude,omitempty"`
+	// KeepBoilerplate disables the built-in removal of scripts, styles,
+	// navigation, asides, forms and hidden elements.
+	KeepBoilerplate bool `json:"keepBoilerplate,omitempty"`
+}
+
+// FrontMatterOptions control the front matter written at the top of Markdown files.
+type FrontMatterOptions struct {
+	// Format is one of FrontMatterYAML, FrontMatterTOML, FrontMatterJSON or FrontMatterNone.
+	Format string `json:"format,omitempty"`
+	// Fields are added to the front matter of every page, replacing extracted
+	// fields of the same name; a null value removes the field. String values
+	// are text/templates that may use {{.Title}}, {{.Host}}, {{.Slug}} and the
+	// extracted fields as {{.Meta.title}}; fields that render empty are left out.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// ConversionOptions tunes how a Converter fetches and renders pages. The zero
+// value of every field means "use the default", so options can be sent as a
+// partial JSON object inside a queued job.
+type ConversionOptions struct {
+	Timeout     Duration          `json:"timeout,omitempty"`     // Per-request HTTP timeout
+	MaxBodySize int64             `json:"maxBodySize,omitempty"` // Maximum response body size in bytes
+	UserAgent   string            `json:"userAgent,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"` // Extra request headers
+
+	Workers    int `json:"workers,omitempty"`
+	MaxPerHost int `json:"maxPerHost,omitempty"`
+
+	// OutputFormat is FormatMarkdown or FormatHTML.
+	OutputFormat string `json:"outputFormat,omitempty"`
+	// FilenameTemplate is a text/template for the output file name, without the
+	// extension. It may use {{.Title}}, {{.Host}} and {{.Slug}}; the result is
+	// passed through SanitizeFilename.
+	FilenameTemplate string `json:"filenameTemplate,omitempty"`
+
+	Extraction  ExtractionRules    `json:"extraction,omitempty"`
+	FrontMatter FrontMatterOptions `json:"frontMatter,omitempty"`
+
+	// Profiles holds per-site extraction rules that override Extraction for
+	// the URLs they match. When nil, the profiles file named by
+	// EXTRACTION_PROFILES, a local path or an oci://<bucket>/<object>
+	// location, is loaded, if any. It can only be set in code.
+	Profiles *ProfileRegistry `json:"-"`
+
+	// URLValidator decides which URLs and addresses pages may be fetched from.
+	// When nil, the strict policy from ssrf.PolicyFromEnv is used. It can only
+	// be set in code, never from a job's JSON options.
+	URLValidator ssrf.Validator `json:"-"`
+}
+
+// Option configures a Converter at construction time.
+type Option func(*ConversionOptions)
+
+// WithConversionOptions applies every non-zero field of opts. A nil opts is ignored,
+// which makes it convenient to pass an optional job-level configuration.
+func WithConversionOptions(opts *ConversionOptions) Option {
+	return func(o *ConversionOptions) {
+		if opts == nil {
+			return
+		}
+		if opts.Timeout != 0 {
+			o.Timeout = opts.Timeout
+		}
+		if opts.MaxBodySize != 0 {
+			o.MaxBodySize = opts.MaxBodySize
+		}
+		if opts.UserAgent != "" {
+			o.UserAgent = opts.UserAgent
+		}
+		for k, v := range opts.Headers {
+			WithHeader(k, v)(o)
+		}
+		if opts.Workers != 0 {
+			o.Workers = opts.Workers
+		}
+		if opts.MaxPerHost != 0 {
+			o.MaxPerHost = opts.MaxPerHost
+		}
+		if opts.OutputFormat != "" {
+			o.OutputFormat = opts.OutputFormat
+		}
+		if opts.FilenameTemplate != "" {
+			o.FilenameTemplate = opts.FilenameTemplate
+		}
+		if opts.Extraction.Selector != "" {
+			o.Extraction.Selector = opts.Extraction.Selector
+		}
+		o.Extraction.Exclude = append(o.Extraction.Exclude, opts.Extraction.Exclude...)
+		if opts.Extraction.KeepBoilerplate {
+			o.Extraction.KeepBoilerplate = true
+		}
+		if opts.FrontMatter.Format != "" {
+			o.FrontMatter.Format = opts.FrontMatter.Format
+		}
+		for k, v := range opts.FrontMatter.Fields {
+			WithFrontMatterField(k, v)(o)
+		}
+		if opts.Profiles != nil {
+			o.Profiles = opts.Profiles
+		}
+		if opts.URLValidator != nil {
+			o.URLValidator = opts.URLValidator
+		}
+	}
+}
+
+// WithTimeout sets the per-request HTTP timeout. Zero uses the default, and a
+// timeout above one minute makes the constructor fail.
+func WithTimeout(d time.Duration) Option {
+	return func(o *ConversionOptions) { o.Timeout = Duration(d) }
+}
+
+// WithMaxBodySize sets the maximum number of bytes read from a response. Zero
+// uses the default, and a size above 50MB makes the constructor fail.
+func WithMaxBodySize(n int64) Option {
+	return func(o *ConversionOptions) { o.MaxBodySize = n }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(o *ConversionOptions) { o.UserAgent = ua }
+}
+
+// WithHeader adds an extra header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(o *ConversionOptions) {
+		if o.Headers == nil {
+			o.Headers = make(map[string]string)
+		}
+		o.Headers[key] = value
+	}
+}
+
+// WithConcurrency sets the worker count and the per-host in-flight limit. Zero
+// uses the default, and more than 32 workers or 8 requests per host make the
+// constructor fail.
+func WithConcurrency(workers, maxPerHost int) Option {
+	return func(o *ConversionOptions) {
+		o.Workers = workers
+		o.MaxPerHost = maxPerHost
+	}
+}
+
+// WithOutputFormat selects FormatMarkdown or FormatHTML output.
+func WithOutputFormat(format string) Option {
+	return func(o *ConversionOptions) { o.OutputFormat = format }
+}
+
+// WithFilenameTemplate sets the template used to name output files.
+func WithFilenameTemplate(tmpl string) Option {
+	return func(o *ConversionOptions) { o.FilenameTemplate = tmpl }
+}
+
+// WithSelector sets the default content selector.
+func WithSelector(selector string) Option {
+	return func(o *ConversionOptions) { o.Extraction.Selector = selector }
+}
+
+// WithExclude adds CSS selectors for elements removed from the selected content.
+func WithExclude(selectors ...string) Option {
+	return func(o *ConversionOptions) { o.Extraction.Exclude = append(o.Extraction.Exclude, selectors...) }
+}
+
+// WithKeepBoilerplate disables the built-in boilerplate filter.
+func WithKeepBoilerplate() Option {
+	return func(o *ConversionOptions) { o.Extraction.KeepBoilerplate = true }
+}
+
+// WithFrontMatter selects the front matter format.
+func WithFrontMatter(format string) Option {
+	return func(o *ConversionOptions) { o.FrontMatter.Format = format }
+}
+
+// WithFrontMatterField adds a static or templated field to the front matter.
+func WithFrontMatterField(key string, value interface{}) Option {
+	return func(o *ConversionOptions) {
+		if o.FrontMatter.Fields == nil {
+			o.FrontMatter.Fields = make(map[string]interface{})
+		}
+		o.FrontMatter.Fields[key] = value
+	}
+}
+
+// WithProfiles sets the extraction profiles used to pick rules per URL.
+func WithProfiles(profiles *ProfileRegistry) Option {
+	return func(o *ConversionOptions) { o.Profiles = profiles }
+}
+
+// WithURLValidator replaces the SSRF policy, for example with
+// ssrf.AllowAddrPorts to let tests fetch from specific local servers.
+func WithURLValidator(v ssrf.Validator) Option {
+	return func(o *ConversionOptions) { o.URLValidator = v }
+}
+
+// defaultOptions returns the options used when nothing is overridden.
+func defaultOptions() ConversionOptions {
+	return ConversionOptions{
+		Timeout:          Duration(httpTimeout),
+		MaxBodySize:      maxBodySize,
+		UserAgent:        defaultUserAgent,
+		Workers:          defaultWorkers,
+		MaxPerHost:       defaultMaxPerHost,
+		OutputFormat:     FormatMarkdown,
+		FilenameTemplate: defaultFilenameTemplate,
+		FrontMatter:      FrontMatterOptions{Format: FrontMatterYAML},
+	}
+}
+
+// resolveOptions applies opts over the defaults and validates the result. The
+// caps of Validate apply to functional options as well as to JSON options, and
+// a zero resource setting means "use the default" for both.
+func resolveOptions(opts []Option) (ConversionOptions, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := options.Validate(); err != nil {
+		return ConversionOptions{}, err
+	}
+
+	defaults := defaultOptions()
+	if options.Timeout == 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.MaxBodySize == 0 {
+		options.MaxBodySize = defaults.MaxBodySize
+	}
+	if options.Workers == 0 {
+		options.Workers = defaults.Workers
+	}
+	if options.MaxPerHost == 0 {
+		options.MaxPerHost = defaults.MaxPerHost
+	}
+	return options, nil
+}
+
+// Validate checks every field of the options and returns an *OptionsError
+// listing the invalid ones, or nil if the options are acceptable. Options come
+// from untrusted job requests, so resource settings are capped as well.
+func (o *ConversionOptions) Validate() error {
+	var errs OptionsError
+
+	if o.Timeout < 0 || o.Timeout > Duration(maxTimeout) {
+		errs.add("timeout", "must be between 0 (the default) and %s", maxTimeout)
+	}
+	if o.MaxBodySize < 0 || o.MaxBodySize > maxBodySizeLimit {
+		errs.add("maxBodySize", "must be between 0 (the default) and %d bytes", maxBodySizeLimit)
+	}
+	if o.Workers < 0 || o.Workers > maxWorkers {
+		errs.add("workers", "must be between 0 (the default) and %d", maxWorkers)
+	}
+	if o.MaxPerHost < 0 || o.MaxPerHost > maxPerHostLimit {
+		errs.add("maxPerHost", "must be between 0 (the default) and %d", maxPerHostLimit)
+	}
+	for key := range o.Headers {
+		if key == "" || strings.ContainsAny(key, " :\r\n") {
+			errs.add("headers", "invalid header name %q", key)
+		} else if textproto.CanonicalMIMEHeaderKey(key) == "Host" {
+			errs.add("headers", "the Host header cannot be overridden")
+		}
+	}
+	switch o.OutputFormat {
+	case "", FormatMarkdown, FormatHTML:
+	default:
+		errs.add("outputFormat", "unsupported output format %q", o.OutputFormat)
+	}
+	for _, selector := range o.Extraction.Exclude {
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			errs.add("extraction.exclude", "invalid selector %q: %v", selector, err)
+		}
+	}
+	if o.FilenameTemplate != "" {
+		if _, err := template.New("filename").Parse(o.FilenameTemplate); err != nil {
+			errs.add("filenameTemplate", "invalid template: %v", err)
+		}
+	}
+	switch o.FrontMatter.Format {
+	case "", FrontMatterYAML, FrontMatterTOML, FrontMatterJSON, FrontMatterNone:
+	default:
+		errs.add("frontMatter.format", "unsupported front matter format %q", o.FrontMatter.Format)
+	}
+	if _, err := parseFieldTemplates(o.FrontMatter.Fields); err != nil {
+		errs.add("frontMatter.fields", "%v", err)
+	}
+
+	if len(errs.Fields) > 0 {
+		return &errs
+	}
+	return nil
+}
+
+// OptionError describes one invalid field of ConversionOptions. Field is the
+// JSON name of the field, such as "workers" or "frontMatter.format".
+type OptionError struct {
+	Field   string
+	Message string
+}
+
+// OptionsError lists the invalid fields found by ConversionOptions.Validate.
+type OptionsError struct {
+	Fields []OptionError
+}
+
+// add records a problem with the named field.
+func (e *OptionsError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, OptionError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Error implements the error interface.
+func (e *OptionsError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// applyHeaders sets the configured User-Agent and extra headers on req.
+func (o *ConversionOptions) applyHeaders(req *http.Request) {
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	for key, value := range o.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// fileExtension returns the extension for files written in the configured format.
+func (o *ConversionOptions) fileExtension() string {
+	if o.OutputFormat == FormatHTML {
+		return ".html"
+	}
+	return ".md"
+}
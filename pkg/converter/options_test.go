@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResolveOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		check   func(ConversionOptions) bool
+		invalid []string // Fields reported by the error, if any
+	}{
+		{
+			name: "defaults",
+			check: func(o ConversionOptions) bool {
+				return o.Timeout == Duration(httpTimeout) && o.Workers == defaultWorkers
+			},
+		},
+		{
+			name:  "zero timeout uses the default",
+			opts:  []Option{WithTimeout(0)},
+			check: func(o ConversionOptions) bool { return o.Timeout == Duration(httpTimeout) },
+		},
+		{
+			name:  "zero JSON timeout uses the default",
+			opts:  []Option{WithConversionOptions(&ConversionOptions{Timeout: 0, Workers: 4})},
+			check: func(o ConversionOptions) bool { return o.Timeout == Duration(httpTimeout) && o.Workers == 4 },
+		},
+		{
+			name: "zero sizes and concurrency use the defaults",
+			opts: []Option{WithMaxBodySize(0), WithConcurrency(0, 0)},
+			check: func(o ConversionOptions) bool {
+				return o.MaxBodySize == maxBodySize && o.MaxPerHost == defaultMaxPerHost
+			},
+		},
+		{
+			name:  "values at the caps",
+			opts:  []Option{WithTimeout(maxTimeout), WithMaxBodySize(maxBodySizeLimit), WithConcurrency(maxWorkers, maxPerHostLimit)},
+			check: func(o ConversionOptions) bool { return o.Timeout == Duration(maxTimeout) && o.Workers == maxWorkers },
+		},
+		{
+			name:    "functional options above the caps",
+			opts:    []Option{WithTimeout(2 * time.Minute), WithMaxBodySize(maxBodySizeLimit + 1), WithConcurrency(maxWorkers+1, maxPerHostLimit+1)},
+			invalid: []string{"timeout", "maxBodySize", "workers", "maxPerHost"},
+		},
+		{
+			name:    "JSON options above the caps",
+			opts:    []Option{WithConversionOptions(&ConversionOptions{Timeout: Duration(time.Hour), Workers: 1000})},
+			invalid: []string{"timeout", "workers"},
+		},
+		{
+			name:    "negative values",
+			opts:    []Option{WithTimeout(-time.Second), WithConcurrency(-1, 1)},
+			invalid: []string{"timeout", "workers"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := resolveOptions(tt.opts)
+			if len(tt.invalid) == 0 {
+				if err != nil {
+					t.Fatalf("resolveOptions() = %v, want no error", err)
+				}
+				if !tt.check(options) {
+					t.Fatalf("resolveOptions() = %+v", options)
+				}
+				return
+			}
+
+			var optionsErr *OptionsError
+			if !errors.As(err, &optionsErr) {
+				t.Fatalf("resolveOptions() = %v, want an *OptionsError", err)
+			}
+			var fields []string
+			for _, f := range optionsErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			if !slices.Equal(fields, tt.invalid) {
+				t.Fatalf("invalid fields = %v, want %v", fields, tt.invalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v2"
+)
+
+// Extraction profiles let one batch mix sites with different layouts. A
+// profiles file is YAML of the form:
+//
+//	profiles:
+//	  - name: oracle-docs
+//	    match: [docs.oracle.com]
+//	    include: "#content"
+//	    exclude: [".feedback", "#rightNav"]
+//	    title: "h1.title"
+//	    metadata:
+//	      product: "meta[name=product]@content"
+//	  - name: github
+//	    match: [github.com]
+//	    include: "article.markdown-body"
+//
+// The first profile with a matching pattern is used for a URL.
+//
+// EXTRACTION_PROFILES names the profiles file used when none is given in code.
+// The function images contain only the function binary, so deployed functions
+// read it from Object Storage instead: set EXTRACTION_PROFILES to
+// "oci://<bucket>/<object>" in the function configuration, and the object is
+// loaded from the OBJECT_STORAGE_NAMESPACE namespace with the function's
+// credentials, which need read access to the bucket. Uploading a new version
+// of the object takes effect with the next job, without a redeploy.
+
+const (
+	// maxProfilesSize caps the size of a profiles file read from Object Storage.
+	maxProfilesSize = 1 << 20 // 1MB
+	// profilesTimeout bounds the download of a profiles file from Object Storage.
+	profilesTimeout = 30 * time.Second
+)
+
+// Profile holds the extraction rules for the pages of one site or section.
+type Profile struct {
+	// Name identifies the profile in log messages.
+	Name string `yaml:"name"`
+	// Match lists the URLs the profile applies to. A pattern is a host,
+	// optionally followed by a path prefix, such as "docs.oracle.com" or
+	// "github.com/oracle/". A host starting with "*." matches any subdomain.
+	Match []string `yaml:"match"`
+	// Include is the CSS selector for the main content. It takes precedence
+	// over the selector given for the batch.
+	Include string `yaml:"include"`
+	// Exclude lists CSS selectors for elements removed from the content, in
+	// addition to the excludes of the conversion options.
+	Exclude []string `yaml:"exclude"`
+	// KeepBoilerplate disables the built-in boilerplate filter for these pages.
+	KeepBoilerplate bool `yaml:"keepBoilerplate"`
+	// Title is the CSS selector of the element holding the page title, used
+	// instead of the title tag for the front matter and the file name.
+	Title string `yaml:"title"`
+	// Metadata maps front matter fields to the elements they are read from.
+	Metadata map[string]MetadataRule `yaml:"metadata"`
+}
+
+// MetadataRule reads one front matter field from the page. In YAML it can be
+// written as a mapping or as the shorthand "selector" or "selector@attr".
+type MetadataRule struct {
+	Selector string `yaml:"selector"`
+	// Attr is the attribute to read; the element text is used when empty.
+	Attr string `yaml:"attr"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting the shorthand form.
+func (r *MetadataRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var shorthand string
+	if err := unmarshal(&shorthand); err == nil {
+		r.Selector, r.Attr = shorthand, ""
+		if i := strings.LastIndex(shorthand, "@"); i > 0 {
+			r.Selector, r.Attr = shorthand[:i], shorthand[i+1:]
+		}
+		return nil
+	}
+
+	type plain MetadataRule
+	return unmarshal((*plain)(r))
+}
+
+// value returns the field value from doc, or "" if no element matches.
+func (r MetadataRule) value(doc *goquery.Document) string {
+	sel := doc.Find(r.Selector).First()
+	if r.Attr != "" {
+		return strings.TrimSpace(sel.AttrOr(r.Attr, ""))
+	}
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(sel.Text(), " "))
+}
+
+// ProfileRegistry holds the extraction profiles loaded from a profiles file.
+// A nil registry has no profiles.
+type ProfileRegistry struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// LoadProfiles reads and validates a profiles file.
+func LoadProfiles(path string) (*ProfileRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	registry, err := ParseProfiles(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return registry, nil
+}
+
+// ParseProfiles parses and validates the YAML content of a profiles file.
+// Unknown fields are rejected so that typos do not silently disable a rule.
+func ParseProfiles(data []byte) (*ProfileRegistry, error) {
+	var registry ProfileRegistry
+	if err := yaml.UnmarshalStrict(data, &registry); err != nil {
+		return nil, fmt.Errorf("invalid profiles: %v", err)
+	}
+	for i := range registry.Profiles {
+		if err := registry.Profiles[i].validate(); err != nil {
+			return nil, fmt.Errorf("profile %d (%s): %w", i+1, registry.Profiles[i].Name, err)
+		}
+	}
+	return &registry, nil
+}
+
+// ProfilesFromEnv loads the profiles file named by EXTRACTION_PROFILES, either
+// a local path or an "oci://<bucket>/<object>" location in Object Storage. It
+// returns nil if the variable is not set.
+func ProfilesFromEnv() (*ProfileRegistry, error) {
+	location := os.Getenv("EXTRACTION_PROFILES")
+	if location == "" {
+		return nil, nil
+	}
+	if ref, ok := strings.CutPrefix(location, "oci://"); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), profilesTimeout)
+		defer cancel()
+		return loadObjectProfiles(ctx, ref)
+	}
+	return LoadProfiles(location)
+}
+
+// loadObjectProfiles reads and validates a profiles file stored in Object
+// Storage, given as "<bucket>/<object>".
+func loadObjectProfiles(ctx context.Context, ref string) (*ProfileRegistry, error) {
+	bucket, object, ok := strings.Cut(ref, "/")
+	if !ok || bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid profiles location oci://%s: expected oci://<bucket>/<object>", ref)
+	}
+
+	storage, err := NewObjectStorageFromEnv(os.Getenv("OBJECT_STORAGE_NAMESPACE"), bucket, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	r, err := storage.Open(ctx, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxProfilesSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	if len(data) > maxProfilesSize {
+		return nil, fmt.Errorf("oci://%s: profiles file is larger than %d bytes", ref, maxProfilesSize)
+	}
+
+	registry, err := ParseProfiles(data)
+	if err != nil {
+		return nil, fmt.Errorf("oci://%s: %w", ref, err)
+	}
+	return registry, nil
+}
+
+// Match returns the first profile matching rawURL, or nil if there is none.
+func (r *ProfileRegistry) Match(rawURL string) *Profile {
+	if r == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	for i := range r.Profiles {
+		for _, pattern := range r.Profiles[i].Match {
+			if matchPattern(pattern, u) {
+				return &r.Profiles[i]
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the patterns and selectors of the profile.
+func (p *Profile) validate() error {
+	if len(p.Match) == 0 {
+		return fmt.Errorf("match is required")
+	}
+	for _, pattern := range p.Match {
+		if pattern == "" || strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "://") {
+			return fmt.Errorf("invalid match pattern %q: expected a host with an optional path", pattern)
+		}
+	}
+
+	selectors := append([]string{p.Include, p.Title}, p.Exclude...)
+	for _, rule := range p.Metadata {
+		if rule.Selector == "" {
+			return fmt.Errorf("metadata rules require a selector")
+		}
+		selectors = append(selectors, rule.Selector)
+	}
+	for _, selector := range selectors {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+	}
+	return nil
+}
+
+// matchPattern reports whether u matches a "host[/path-prefix]" pattern.
+func matchPattern(pattern string, u *url.URL) bool {
+	host, prefix, _ := strings.Cut(pattern, "/")
+	host, hostname := strings.ToLower(host), strings.ToLower(u.Hostname())
+
+	if domain, ok := strings.CutPrefix(host, "*."); ok {
+		if !strings.HasSuffix(hostname, "."+domain) {
+			return false
+		}
+	} else if hostname != host {
+		return false
+	}
+	return prefix == "" || strings.HasPrefix(u.Path, "/"+prefix)
+}
@@ -0,0 +1,167 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Storage is the sink converted files are written to.
+type Storage interface {
+	// Put stores data under name, replacing any existing file.
+	Put(ctx context.Context, name string, data []byte) error
+	// Open returns a reader for the file stored under name. If the file does not
+	// exist, the returned error wraps os.ErrNotExist.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// StreamStorage is implemented by storages that can store a file of unknown
+// size while it is being produced, without holding all of it in memory.
+type StreamStorage interface {
+	Storage
+	// PutStream stores everything read from r under name, replacing any
+	// existing file. Nothing is stored if reading r fails.
+	PutStream(ctx context.Context, name string, r io.Reader) error
+}
+
+// FileStorage stores files in a directory on the local filesystem.
+type FileStorage struct {
+	Dir string
+}
+
+// NewFileStorage creates a FileStorage rooted at dir, creating the directory if needed.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory %s: %w", dir, err)
+	}
+	return &FileStorage{Dir: dir}, nil
+}
+
+// Put writes data to name inside the storage directory.
+func (s *FileStorage) Put(ctx context.Context, name string, data []byte) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// PutStream writes r to a temporary file and renames it to name once complete,
+// so that a partially written file is never visible under its final name.
+func (s *FileStorage) PutStream(ctx context.Context, name string, r io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op once renamed
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Open opens name inside the storage directory.
+func (s *FileStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// path resolves name inside the storage directory, rejecting names that would escape it.
+func (s *FileStorage) path(name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return filepath.Join(s.Dir, name), nil
+}
+
+// MemoryStorage keeps files in memory. It is safe for concurrent use and is
+// mainly intended for tests.
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+// Put stores a copy of data under name.
+func (s *MemoryStorage) Put(ctx context.Context, name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.files == nil {
+		s.files = make(map[string][]byte)
+	}
+	s.files[name] = bytes.Clone(data)
+	return nil
+}
+
+// PutStream reads r fully and stores its content under name.
+func (s *MemoryStorage) PutStream(ctx context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, name, data)
+}
+
+// Open returns a reader over the file stored under name.
+func (s *MemoryStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := s.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("file %q not found: %w", name, os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Get returns the content stored under name and whether it exists.
+func (s *MemoryStorage) Get(name string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.files[name]
+	return data, ok
+}
+
+// Names returns the names of all stored files in sorted order.
+func (s *MemoryStorage) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
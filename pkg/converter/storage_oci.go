@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"doc-converter-oci-serverless/pkg/ociauth"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage/transfer"
+)
+
+const (
+	// streamPartSize and streamUploaders bound the memory used by PutStream
+	// to about streamPartSize * (streamUploaders + 1).
+	streamPartSize  = 8 * 1024 * 1024 // 8MB
+	streamUploaders = 2
+)
+
+// ObjectStorage stores files as objects in an OCI Object Storage bucket.
+// Object names are the file names with Prefix prepended.
+type ObjectStorage struct {
+	Client    objectstorage.ObjectStorageClient
+	Namespace string
+	Bucket    string
+	Prefix    string
+}
+
+// NewObjectStorageFromEnv creates an ObjectStorage whose client authenticates
+// with the provider selected by ociauth.ProviderFromEnv.
+func NewObjectStorageFromEnv(namespace, bucket, prefix string) (*ObjectStorage, error) {
+	client, err := NewObjectStorageClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewObjectStorage(client, namespace, bucket, prefix)
+}
+
+// NewObjectStorageClientFromEnv creates an Object Storage client that
+// authenticates with the provider selected by ociauth.ProviderFromEnv.
+func NewObjectStorageClientFromEnv() (objectstorage.ObjectStorageClient, error) {
+	provider, err := ociauth.ProviderFromEnv()
+	if err != nil {
+		return objectstorage.ObjectStorageClient{}, err
+	}
+	return objectstorage.NewObjectStorageClientWithConfigurationProvider(provider)
+}
+
+// NewObjectStorage creates an ObjectStorage writing to the given bucket.
+func NewObjectStorage(client objectstorage.ObjectStorageClient, namespace, bucket, prefix string) (*ObjectStorage, error) {
+	if namespace == "" || bucket == "" {
+		return nil, fmt.Errorf("object storage namespace and bucket must be specified")
+	}
+	return &ObjectStorage{
+		Client:    client,
+		Namespace: namespace,
+		Bucket:    bucket,
+		Prefix:    prefix,
+	}, nil
+}
+
+// Put uploads data as the object Prefix+name.
+func (s *ObjectStorage) Put(ctx context.Context, name string, data []byte) error {
+	req := objectstorage.PutObjectRequest{
+		NamespaceName: common.String(s.Namespace),
+		BucketName:    common.String(s.Bucket),
+		ObjectName:    common.String(s.Prefix + name),
+		ContentLength: common.Int64(int64(len(data))),
+		PutObjectBody: io.NopCloser(bytes.NewReader(data)),
+	}
+	if contentType := contentTypeFor(name); contentType != "" {
+		req.ContentType = common.String(contentType)
+	}
+
+	if _, err := s.Client.PutObject(ctx, req); err != nil {
+		return fmt.Errorf("failed to upload object %s: %w", s.Prefix+name, err)
+	}
+	return nil
+}
+
+// PutStream uploads r as the object Prefix+name using a multipart upload, so
+// that at most a few parts are held in memory at once.
+func (s *ObjectStorage) PutStream(ctx context.Context, name string, r io.Reader) error {
+	req := transfer.UploadStreamRequest{
+		UploadRequest: transfer.UploadRequest{
+			NamespaceName:       common.String(s.Namespace),
+			BucketName:          common.String(s.Bucket),
+			ObjectName:          common.String(s.Prefix + name),
+			ObjectStorageClient: &s.Client,
+			PartSize:            common.Int64(streamPartSize),
+			NumberOfGoroutines:  common.Int(streamUploaders),
+		},
+		StreamReader: r,
+	}
+	if contentType := contentTypeFor(name); contentType != "" {
+		req.ContentType = common.String(contentType)
+	}
+
+	if _, err := transfer.NewUploadManager().UploadStream(ctx, req); err != nil {
+		return fmt.Errorf("failed to upload object %s: %w", s.Prefix+name, err)
+	}
+	return nil
+}
+
+// Open downloads the object Prefix+name.
+func (s *ObjectStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := s.Client.GetObject(ctx, objectstorage.GetObjectRequest{
+		NamespaceName: common.String(s.Namespace),
+		BucketName:    common.String(s.Bucket),
+		ObjectName:    common.String(s.Prefix + name),
+	})
+	if err != nil {
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			return nil, fmt.Errorf("object %s not found: %w", s.Prefix+name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to download object %s: %w", s.Prefix+name, err)
+	}
+	return resp.Content, nil
+}
+
+// contentTypeFor returns the MIME type to record for an object name.
+func contentTypeFor(name string) string {
+	if path.Ext(name) == ".md" {
+		return "text/markdown; charset=utf-8"
+	}
+	return mime.TypeByExtension(path.Ext(name))
+}
@@ -0,0 +1,126 @@
+// Package handlers implements the HTTP and queue handlers behind the
+// conversion functions. Each handler implements fdk.Handler, so the same code
+// runs inside OCI Functions and in the local development server.
+package handlers
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/api"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"doc-converter-oci-serverless/pkg/jobs"
+	"doc-converter-oci-serverless/pkg/queue"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// maxRequestSize caps the size of the request body that will be decoded.
+const maxRequestSize = 1 << 20 // 1MB
+
+// CreateJob validates a conversion request, records the new job and publishes
+// it to the job queue.
+type CreateJob struct {
+	// Queue receives the job shards. When nil, a queue is created from the
+	// function config (QUEUE_OCID, or LOCAL_QUEUE_DIR when running locally).
+	Queue queue.JobQueue
+}
+
+// Serve implements fdk.Handler.
+func (h *CreateJob) Serve(ctx context.Context, in io.Reader, out io.Writer) {
+	// 1. Decode and validate the request
+	var req api.ConversionRequest
+	if err := json.NewDecoder(io.LimitReader(in, maxRequestSize)).Decode(&req); err != nil {
+		log.Printf("Invalid request body: %v", err)
+		api.WriteError(out, http.StatusBadRequest, "request body must be a valid JSON conversion request")
+		return
+	}
+
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		var validationErr *api.ValidationError
+		if errors.As(err, &validationErr) {
+			api.WriteError(out, http.StatusUnprocessableEntity, "invalid conversion request", validationErr.Fields...)
+		} else {
+			api.WriteError(out, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	jobID, err := jobid.New()
+	if err != nil {
+		log.Printf("Failed to generate job ID: %v", err)
+		api.WriteError(out, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+
+	// 2. Connect to the job queue
+	jobQueue := h.Queue
+	if jobQueue == nil {
+		jobQueue, err = queue.NewFromEnv()
+		if err != nil {
+			log.Printf("Failed to create job queue client: %v", err)
+			api.WriteError(out, http.StatusInternalServerError, "failed to connect to job queue")
+			return
+		}
+	}
+
+	// 3. Split the job into shards small enough for a single queue message each
+	shards, err := queue.Split(&queue.ConversionJob{
+		URLs:       req.URLs,
+		Selector:   req.Selector,
+		DownloadID: jobID,
+		Options:    req.Options,
+	})
+	if err != nil {
+		log.Printf("Rejected job %s: %v", jobID, err)
+		api.WriteError(out, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 4. Record the job as queued so get-job-status can report on it
+	store, err := jobs.NewStoreFromEnv()
+	if err != nil {
+		log.Printf("Failed to create job status store: %v", err)
+		api.WriteError(out, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+
+	record := jobs.NewRecord(jobID, len(req.URLs))
+	record.ShardCount = len(shards)
+	if err := store.Put(ctx, record); err != nil {
+		log.Printf("Failed to save status for job %s: %v", jobID, err)
+		api.WriteError(out, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+
+	// 5. Publish every shard
+	if err := jobQueue.PutBatch(ctx, shards); err != nil {
+		var partial *queue.PartialPublishError
+		if errors.As(err, &partial) {
+			// The published shards are already running, so a retry by the client
+			// would duplicate them. The job continues, and the shards that were
+			// not queued complete it as partially failed.
+			log.Printf("Published %d of %d shards of job %s: %v", partial.Published, len(shards), jobID, err)
+			for _, shard := range shards[partial.Published:] {
+				failShard(context.WithoutCancel(ctx), shard, fmt.Sprintf("failed to publish shard to queue: %v", partial.Err))
+			}
+			api.WriteJSON(out, http.StatusAccepted, map[string]string{"jobId": jobID})
+			return
+		}
+
+		log.Printf("Failed to publish job %s to queue: %v", jobID, err)
+		record.Fail("failed to publish job to queue")
+		if saveErr := store.Put(ctx, record); saveErr != nil {
+			log.Printf("Failed to save status for job %s: %v", jobID, saveErr)
+		}
+		api.WriteError(out, http.StatusServiceUnavailable, "failed to queue job, please retry")
+		return
+	}
+
+	log.Printf("Job %s queued successfully in %d shard(s)", jobID, len(shards))
+
+	api.WriteJSON(out, http.StatusAccepted, map[string]string{"jobId": jobID})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/api"
+	"doc-converter-oci-serverless/pkg/converter"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fnproject/fdk-go"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// FnContext represents the context provided by the function invocation,
+// including the request path from the API Gateway.
+type FnContext struct {
+	Path string `json:"path"`
+}
+
+// Download redirects to a short-lived link for the archive of the job named
+// in the request path.
+type Download struct {
+	// Link returns the URL the archive of a job can be downloaded from. When
+	// nil, a pre-authenticated request for the archive in the output bucket is created.
+	Link func(ctx context.Context, jobID string) (string, error)
+}
+
+// Serve implements fdk.Handler.
+func (h *Download) Serve(ctx context.Context, in io.Reader, out io.Writer) {
+	// --- 1. Extract the jobID from the request path ---
+	path := requestPath(ctx)
+	if path == "" {
+		// Without an HTTP context, the request body carries the path instead
+		var fnCtx FnContext
+		if err := json.NewDecoder(in).Decode(&fnCtx); err != nil {
+			log.Printf("Error decoding function context: %v", err)
+			api.WriteError(out, http.StatusBadRequest, "missing jobID")
+			return
+		}
+		path = fnCtx.Path
+	}
+
+	jobID := jobIDFromPath(path, "download")
+	if jobID == "" {
+		log.Printf("Could not extract jobID from path: %s", path)
+		api.WriteError(out, http.StatusBadRequest, "missing jobID")
+		return
+	}
+	if !jobid.IsValid(jobID) {
+		api.WriteError(out, http.StatusBadRequest, "invalid jobID")
+		return
+	}
+
+	log.Printf("Received download request for job: %s", jobID)
+
+	// --- 2. Generate the download link ---
+	link := h.Link
+	if link == nil {
+		link = createPAR
+	}
+	downloadURL, err := link(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to create download link for job %s: %v", jobID, err)
+		api.WriteError(out, http.StatusInternalServerError, "failed to generate download link")
+		return
+	}
+
+	log.Printf("Successfully generated download link for job %s", jobID)
+
+	// --- 3. Perform the Redirect ---
+	fdk.SetHeader(out, "Location", downloadURL)
+	fdk.WriteStatus(out, http.StatusFound)
+}
+
+// createPAR generates a Pre-Authenticated Request for the specified object.
+func createPAR(ctx context.Context, jobID string) (string, error) {
+	// Authenticate with the provider selected from the environment
+	// (resource principal within the OCI Function).
+	osClient, err := converter.NewObjectStorageClientFromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	// Get required details from the function's environment variables.
+	namespace := os.Getenv("OBJECT_STORAGE_NAMESPACE")
+	bucketName := os.Getenv("OUTPUT_BUCKET_NAME")
+	region := os.Getenv("OCI_REGION")
+	objectName := converter.ArchiveName(jobID) // The converted file is named after the job ID.
+
+	// Set the expiration time for the PAR.
+	expirationTime := time.Now().Add(15 * time.Minute)
+
+	// Create the request to generate the PAR.
+	req := objectstorage.CreatePreauthenticatedRequestRequest{
+		NamespaceName: &namespace,
+		BucketName:    &bucketName,
+		CreatePreauthenticatedRequestDetails: objectstorage.CreatePreauthenticatedRequestDetails{
+			Name:       common.String("par-for-" + jobID),
+			ObjectName: common.String(objectName),
+			AccessType: objectstorage.CreatePreauthenticatedRequestDetailsAccessTypeObjectread,
+			// FIX: Create an SDKTime struct and pass a pointer to it.
+			TimeExpires: &common.SDKTime{Time: expirationTime},
+		},
+	}
+
+	resp, err := osClient.CreatePreauthenticatedRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	// Construct the full, absolute URL for the download.
+	return "https://objectstorage." + region + ".oraclecloud.com" + *resp.AccessUri, nil
+}
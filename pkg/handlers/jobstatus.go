@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/api"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"doc-converter-oci-serverless/pkg/jobs"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+// JobStatus returns the status record of the job named in the request path.
+type JobStatus struct{}
+
+// Serve implements fdk.Handler.
+func (h *JobStatus) Serve(ctx context.Context, in io.Reader, out io.Writer) {
+	// --- 1. Extract the jobID from the request path ---
+	jobID := jobIDFromPath(requestPath(ctx), "status")
+	if jobID == "" {
+		api.WriteError(out, http.StatusBadRequest, "missing jobID")
+		return
+	}
+	if !jobid.IsValid(jobID) {
+		api.WriteError(out, http.StatusBadRequest, "invalid jobID")
+		return
+	}
+
+	log.Printf("Checking status for job %s", jobID)
+
+	// --- 2. Load the persisted job record ---
+	store, err := jobs.NewStoreFromEnv()
+	if err != nil {
+		log.Printf("Failed to create job status store: %v", err)
+		api.WriteError(out, http.StatusInternalServerError, "failed to read job status")
+		return
+	}
+
+	record, err := store.Get(ctx, jobID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		api.WriteError(out, http.StatusNotFound, "job not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read status for job %s: %v", jobID, err)
+		api.WriteError(out, http.StatusInternalServerError, "failed to read job status")
+		return
+	}
+
+	// --- 3. Return the record, including progress counts and timestamps ---
+	api.WriteJSON(out, http.StatusOK, record)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/fnproject/fdk-go"
+)
+
+// requestPath returns the path of the HTTP request that triggered the
+// invocation, or "" when the function was not invoked over HTTP.
+func requestPath(ctx context.Context) string {
+	httpCtx, ok := fdk.GetContext(ctx).(fdk.HTTPContext)
+	if !ok {
+		return ""
+	}
+
+	requestURL := httpCtx.RequestURL()
+	if parsedURL, err := url.Parse(requestURL); err == nil {
+		return parsedURL.Path
+	}
+	return requestURL
+}
+
+// jobIDFromPath extracts the job ID from a path of the form
+// .../jobs/<jobID>/<action>, such as /api/v1/jobs/some-job-id/status.
+// It returns "" if the path does not match.
+func jobIDFromPath(path, action string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == "jobs" && parts[i+2] == action {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
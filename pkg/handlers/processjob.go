@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/converter"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"doc-converter-oci-serverless/pkg/jobs"
+	"doc-converter-oci-serverless/pkg/queue"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// progressInterval is the minimum time between job status updates during a conversion.
+	progressInterval = 5 * time.Second
+	// visibilityTimeout is how long a message stays hidden from other consumers
+	// after each extension while its job is being processed.
+	visibilityTimeout = 2 * time.Minute
+	// visibilityExtendInterval is how often the visibility timeout is extended.
+	visibilityExtendInterval = 30 * time.Second
+)
+
+// OCIQueueEvent represents the structure of the event from an OCI Queue trigger
+type OCIQueueEvent struct {
+	Messages []struct {
+		ID            int64  `json:"id"`
+		Content       string `json:"content"`
+		Receipt       string `json:"receipt"`
+		DeliveryCount int    `json:"deliveryCount"`
+	} `json:"messages"`
+}
+
+// permanentError marks a failure that will not go away on retry, such as a
+// malformed message. Messages failing permanently are acknowledged, not released.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// ProcessJob runs the conversion jobs delivered by the job queue.
+type ProcessJob struct {
+	// Queue is the queue the messages were received from. When nil, the queue,
+	// dead-letter queue and retry policy are created from the function config
+	// on every invocation.
+	Queue       queue.JobQueue
+	DeadLetters queue.JobQueue // Optional; failed jobs are dropped without one
+	Policy      queue.RetryPolicy
+}
+
+// Serve implements fdk.Handler for events from an OCI Queue trigger.
+func (h *ProcessJob) Serve(ctx context.Context, in io.Reader, out io.Writer) {
+	// 1. Decode the incoming event from the OCI Queue trigger
+	var event OCIQueueEvent
+	if err := json.NewDecoder(in).Decode(&event); err != nil {
+		log.Printf("ERROR: Failed to decode queue event: %v", err)
+		return
+	}
+
+	handler := *h
+	if handler.Queue == nil {
+		jobQueue, err := queue.NewFromEnv()
+		if err != nil {
+			// Without a queue client nothing can be acknowledged; the messages become
+			// visible again when their visibility timeout expires.
+			log.Printf("ERROR: Failed to create job queue client: %v", err)
+			return
+		}
+		handler.Queue = jobQueue
+
+		deadLetters, err := queue.NewDeadLetterFromEnv()
+		if err != nil {
+			log.Printf("WARN: Failed to create dead-letter queue client, failed jobs will be dropped: %v", err)
+		}
+		handler.DeadLetters = deadLetters
+		handler.Policy = queue.RetryPolicyFromEnv()
+	}
+
+	messages := make([]queue.Message, len(event.Messages))
+	for i, message := range event.Messages {
+		messages[i] = queue.Message{
+			ID:            strconv.FormatInt(message.ID, 10),
+			Receipt:       message.Receipt,
+			DeliveryCount: message.DeliveryCount,
+			Content:       message.Content,
+		}
+	}
+	handler.Process(ctx, messages)
+}
+
+// Process runs the job of each message and settles the message afterwards.
+// A single trigger can contain multiple messages; each one is settled on its own.
+func (h *ProcessJob) Process(ctx context.Context, messages []queue.Message) {
+	for _, msg := range messages {
+		job, err := processMessage(ctx, h.Queue, msg)
+		settleMessage(context.WithoutCancel(ctx), h.Queue, h.DeadLetters, h.Policy, msg, job, err)
+	}
+}
+
+// processMessage runs the conversion job carried by a single queue message,
+// keeping the message invisible to other consumers while the job runs. The
+// decoded job is returned whenever the message could be decoded.
+func processMessage(ctx context.Context, jobQueue queue.JobQueue, msg queue.Message) (*queue.ConversionJob, error) {
+	// 2. Unmarshal the message content into your job struct
+	job, err := msg.Job()
+	if err != nil {
+		return nil, permanent(err)
+	}
+	if err := jobid.Validate(job.DownloadID); err != nil {
+		return job, permanent(err)
+	}
+
+	return job, runJob(ctx, jobQueue, msg, job)
+}
+
+// runJob converts the URLs of a job, tracks its status and stores its archive.
+func runJob(ctx context.Context, jobQueue queue.JobQueue, msg queue.Message, job *queue.ConversionJob) (err error) {
+	// Recover from a panic outside the page conversions, which the converter's
+	// workers recover themselves, so that it counts as a failed attempt instead
+	// of taking the whole batch down with it
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.DownloadID, r)
+		}
+	}()
+
+	log.Printf("Processing job %s (message %s, delivery %d)", job.DownloadID, msg.ID, msg.DeliveryCount)
+
+	stop := keepInvisible(ctx, jobQueue, msg.Receipt)
+	defer stop()
+
+	files, root, err := newJobStorage(job.DownloadID)
+	if err != nil {
+		return fmt.Errorf("failed to create storage for job %s: %w", job.DownloadID, err)
+	}
+	store := jobs.NewStore(root)
+
+	// Progress is tracked per shard; get-job-status aggregates the shard records
+	shard := jobs.NewShardRecord(job.DownloadID, job.Shard, queue.ShardCountOf(job), len(job.URLs))
+	shard.Start()
+	saveShard(ctx, store, shard)
+
+	c, err := converter.NewConverterWithStorage(job.DownloadID, files, converter.WithConversionOptions(job.Options))
+	if err != nil {
+		shard.Fail(err.Error())
+		saveShard(context.WithoutCancel(ctx), store, shard)
+		return permanent(fmt.Errorf("failed to create new converter for job %s: %w", job.DownloadID, err))
+	}
+	// Shards write into the same job prefix, so their file names are kept apart
+	// by the shard number, as in "02-index.md"
+	if count := queue.ShardCountOf(job); count > 1 {
+		c.FilePrefix = fmt.Sprintf("%0*d-", len(strconv.Itoa(count)), job.Shard+1)
+	}
+
+	resultsChan, summaryChan := c.ConvertContext(ctx, job.URLs, job.Selector)
+
+	var results []converter.Result
+	lastSaved := time.Now()
+	for result := range resultsChan {
+		// The content is already in storage; keeping it would grow with the job
+		result.Content = nil
+		results = append(results, result)
+
+		// Persist progress periodically rather than after every URL
+		shard.Observe(result)
+		if time.Since(lastSaved) >= progressInterval {
+			saveShard(ctx, store, shard)
+			lastSaved = time.Now()
+		}
+	}
+
+	summary := <-summaryChan
+	log.Printf("INFO: Conversion finished for job %s shard %d/%d. Successful: %d, Failed: %d, Cancelled: %d",
+		job.DownloadID, job.Shard+1, queue.ShardCountOf(job), summary.Successful, summary.Failed, summary.Cancelled)
+
+	// A conversion cut short by the invocation deadline is retried from the start
+	if summary.Cancelled > 0 {
+		shard.Requeue("conversion was interrupted and will be retried")
+		saveShard(context.WithoutCancel(ctx), store, shard)
+		return fmt.Errorf("conversion of job %s was cancelled for %d URLs", job.DownloadID, summary.Cancelled)
+	}
+
+	// The shard status must be saved even if the invocation deadline has passed
+	shard.Results = results
+	shard.Finish(summary)
+	if err := store.PutShard(context.WithoutCancel(ctx), shard); err != nil {
+		return err
+	}
+
+	// 3. Once every shard is done, bundle the converted files into <jobID>.zip
+	return finalizeJob(context.WithoutCancel(ctx), store, root, files, job)
+}
+
+// finalizeJob builds the archive and completes the job record once the last
+// shard of a job has finished; for earlier shards it does nothing. Two shards
+// finishing at the same moment may both build the archive, which is harmless
+// because they produce the same result.
+func finalizeJob(ctx context.Context, store *jobs.Store, root, files converter.Storage, job *queue.ConversionJob) error {
+	shardCount := queue.ShardCountOf(job)
+	shards, err := store.Shards(ctx, job.DownloadID, shardCount)
+	if err != nil {
+		return fmt.Errorf("failed to load shards of job %s: %w", job.DownloadID, err)
+	}
+	if !jobs.ShardsComplete(shards) {
+		log.Printf("INFO: Waiting for remaining shards of job %s", job.DownloadID)
+		return nil
+	}
+
+	results, summary := jobs.MergeShards(shards)
+	if err := converter.StoreArchive(ctx, root, files, results, summary); err != nil {
+		return fmt.Errorf("failed to store archive for job %s: %w", job.DownloadID, err)
+	}
+	log.Printf("INFO: Archive %s stored", converter.ArchiveName(job.DownloadID))
+
+	record, err := store.Get(ctx, job.DownloadID)
+	if err != nil {
+		record = jobs.NewRecord(job.DownloadID, summary.TotalURLs)
+		record.ShardCount = shardCount
+	}
+	record.Finish(summary)
+	for _, shard := range shards {
+		// Surface why a shard that was given up on failed
+		if shard.Error != "" {
+			record.Error = shard.Error
+			break
+		}
+	}
+	saveRecord(ctx, store, record)
+	return nil
+}
+
+// settleMessage acknowledges a message whose job completed. A job that failed
+// transiently is republished with its attempt counter incremented; once the retry
+// policy is exhausted, or if the failure is permanent, the message is moved to the
+// dead-letter queue with the failure reason attached.
+func settleMessage(ctx context.Context, jobQueue, deadLetters queue.JobQueue, policy queue.RetryPolicy, msg queue.Message, job *queue.ConversionJob, err error) {
+	if err == nil {
+		ackMessage(ctx, jobQueue, msg)
+		return
+	}
+
+	attempts := queue.Attempts(job, msg)
+	var permanentErr *permanentError
+	if !errors.As(err, &permanentErr) && !policy.Exhausted(attempts) {
+		log.Printf("ERROR: Attempt %d of message %s failed, retrying: %v", attempts, msg.ID, err)
+		if retryErr := queue.Retry(ctx, jobQueue, job, attempts, err.Error()); retryErr != nil {
+			log.Printf("ERROR: Failed to republish message %s, releasing it instead: %v", msg.ID, retryErr)
+			if nackErr := jobQueue.Nack(ctx, msg.Receipt); nackErr != nil {
+				log.Printf("ERROR: Failed to release message %s: %v", msg.ID, nackErr)
+			}
+			return
+		}
+		ackMessage(ctx, jobQueue, msg)
+		return
+	}
+
+	reason := fmt.Sprintf("attempt %d failed: %v", attempts, err)
+	if job != nil && jobid.IsValid(job.DownloadID) {
+		failShard(ctx, job, reason)
+	}
+
+	if deadLetters == nil {
+		log.Printf("ERROR: Dropping message %s, no dead-letter queue configured: %s", msg.ID, reason)
+		ackMessage(ctx, jobQueue, msg)
+		return
+	}
+
+	if dlqErr := queue.DeadLetter(ctx, deadLetters, job, msg, attempts, reason); dlqErr != nil {
+		// Keep the message rather than lose it; it will be retried once visible again
+		log.Printf("ERROR: %v", dlqErr)
+		if nackErr := jobQueue.Nack(ctx, msg.Receipt); nackErr != nil {
+			log.Printf("ERROR: Failed to release message %s: %v", msg.ID, nackErr)
+		}
+		return
+	}
+	log.Printf("ERROR: Moved message %s to the dead-letter queue: %s", msg.ID, reason)
+	ackMessage(ctx, jobQueue, msg)
+}
+
+// ackMessage acknowledges a message, logging any failure.
+func ackMessage(ctx context.Context, jobQueue queue.JobQueue, msg queue.Message) {
+	if err := jobQueue.Ack(ctx, msg.Receipt); err != nil {
+		log.Printf("ERROR: Failed to acknowledge message %s: %v", msg.ID, err)
+	}
+}
+
+// failShard records a shard that has been given up on, or that could not be
+// queued at all, as finished with every URL failed, so that the last shard of the job still archives the others and
+// completes the job as partially_failed, or failed if nothing converted. If the
+// job cannot be finalized, it is marked failed outright.
+func failShard(ctx context.Context, job *queue.ConversionJob, reason string) {
+	files, root, err := newJobStorage(job.DownloadID)
+	if err != nil {
+		log.Printf("WARN: Failed to create storage for job %s: %v", job.DownloadID, err)
+		return
+	}
+	store := jobs.NewStore(root)
+
+	results := make([]converter.Result, len(job.URLs))
+	for i, u := range job.URLs {
+		results[i] = converter.Result{URL: u, Error: reason}
+	}
+	shard := jobs.NewShardRecord(job.DownloadID, job.Shard, queue.ShardCountOf(job), len(job.URLs))
+	shard.Results = results
+	shard.Finish(converter.Summary{
+		TotalURLs:  len(job.URLs),
+		Failed:     len(job.URLs),
+		FailedURLs: job.URLs,
+		DownloadID: job.DownloadID,
+	})
+	shard.Error = reason
+
+	err = store.PutShard(ctx, shard)
+	if err == nil {
+		err = finalizeJob(ctx, store, root, files, job)
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to finalize job %s after giving up on shard %d: %v", job.DownloadID, job.Shard, err)
+		record, getErr := store.Get(ctx, job.DownloadID)
+		if getErr != nil {
+			record = jobs.NewRecord(job.DownloadID, 0)
+		}
+		record.Fail(reason)
+		saveRecord(ctx, store, record)
+	}
+}
+
+// keepInvisible periodically extends the visibility timeout of a message so
+// that long jobs are not redelivered to another consumer while still running.
+// The returned function stops the extensions.
+func keepInvisible(ctx context.Context, jobQueue queue.JobQueue, receipt string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(visibilityExtendInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := jobQueue.ExtendVisibility(ctx, receipt, visibilityTimeout); err != nil && ctx.Err() == nil {
+				log.Printf("WARN: Failed to extend message visibility: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// saveRecord persists a job record, logging rather than failing on errors so that
+// a status update problem never aborts the conversion itself.
+func saveRecord(ctx context.Context, store *jobs.Store, record *jobs.Record) {
+	if err := store.Put(ctx, record); err != nil {
+		log.Printf("WARN: Failed to save status for job %s: %v", record.JobID, err)
+	}
+}
+
+// saveShard persists the record of a single shard, logging any error.
+func saveShard(ctx context.Context, store *jobs.Store, record *jobs.Record) {
+	if err := store.PutShard(ctx, record); err != nil {
+		log.Printf("WARN: Failed to save status for job %s shard %d: %v", record.JobID, record.Shard, err)
+	}
+}
+
+// newJobStorage returns the storage a job's converted files are written to and the
+// root storage its archive and status record are written to. When
+// OUTPUT_BUCKET_NAME is set, files go into the output bucket under "<jobID>/" and
+// the archive to the bucket root as "<jobID>.zip"; otherwise both are written
+// under the local tmp/downloads directory.
+func newJobStorage(jobID string) (files, root converter.Storage, err error) {
+	if err := jobid.Validate(jobID); err != nil {
+		return nil, nil, err
+	}
+
+	bucketName := os.Getenv("OUTPUT_BUCKET_NAME")
+	if bucketName == "" {
+		rootDir, err := converter.NewFileStorage(filepath.Join("tmp", "downloads"))
+		if err != nil {
+			return nil, nil, err
+		}
+		jobFiles, err := converter.NewFileStorage(filepath.Join(rootDir.Dir, jobID))
+		if err != nil {
+			return nil, nil, err
+		}
+		return jobFiles, rootDir, nil
+	}
+
+	namespace := os.Getenv("OBJECT_STORAGE_NAMESPACE")
+	jobFiles, err := converter.NewObjectStorageFromEnv(namespace, bucketName, jobID+"/")
+	if err != nil {
+		return nil, nil, err
+	}
+	bucketRoot, err := converter.NewObjectStorage(jobFiles.Client, namespace, bucketName, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return jobFiles, bucketRoot, nil
+}
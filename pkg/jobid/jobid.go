@@ -0,0 +1,100 @@
+// Package jobid generates and validates conversion job IDs.
+//
+// Job IDs are UUIDv7 values (RFC 9562) in their canonical lowercase form. The
+// leading 48 bits are a millisecond Unix timestamp, so IDs sort by creation
+// time, and the remaining bits are random, so concurrent jobs never collide.
+package jobid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var idRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+var (
+	mu     sync.Mutex
+	lastMs int64
+	seq    uint16 // 12-bit counter keeping IDs generated in the same millisecond ordered
+)
+
+// New returns a new, unique job ID.
+func New() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	ms, counter := nextTimestamp(binary.BigEndian.Uint16(b[6:8]) & 0x07ff)
+
+	// 48-bit big-endian timestamp
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+
+	// Version 7 followed by the 12-bit counter, then the RFC 9562 variant
+	b[6] = 0x70 | byte(counter>>8)
+	b[7] = byte(counter)
+	b[8] = 0x80 | (b[8] & 0x3f)
+
+	return format(b), nil
+}
+
+// Validate reports an error if id is not a well-formed job ID.
+func Validate(id string) error {
+	if !idRegex.MatchString(id) {
+		return fmt.Errorf("invalid job ID %q", id)
+	}
+	return nil
+}
+
+// IsValid reports whether id is a well-formed job ID.
+func IsValid(id string) bool {
+	return Validate(id) == nil
+}
+
+// nextTimestamp returns the current millisecond timestamp and a counter that
+// increases within a millisecond. A new millisecond starts the counter at a
+// random value in the lower half of its range, leaving room to increment.
+func nextTimestamp(random uint16) (int64, uint16) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ms := time.Now().UnixMilli()
+	if ms > lastMs {
+		lastMs = ms
+		seq = random
+		return ms, seq
+	}
+
+	seq++
+	if seq > 0x0fff {
+		// Counter exhausted: borrow the next millisecond.
+		lastMs++
+		seq = random
+	}
+	return lastMs, seq
+}
+
+// format renders b in the canonical 8-4-4-4-12 form.
+func format(b [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
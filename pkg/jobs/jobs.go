@@ -0,0 +1,358 @@
+// Package jobs tracks the status of conversion jobs across the serverless functions.
+package jobs
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/converter"
+	"doc-converter-oci-serverless/pkg/jobid"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultRetention is how long a job and its archive remain downloadable.
+const DefaultRetention = 24 * time.Hour
+
+// ErrNotFound is returned when no record exists for a job ID.
+var ErrNotFound = errors.New("job not found")
+
+// State is the lifecycle state of a job.
+type State string
+
+const (
+	StateQueued          State = "queued"
+	StateRunning         State = "running"
+	StateSucceeded       State = "succeeded"
+	StatePartiallyFailed State = "partially_failed"
+	StateFailed          State = "failed"
+	StateExpired         State = "expired"
+)
+
+// IsTerminal reports whether no further progress will be made in this state.
+func (s State) IsTerminal() bool {
+	switch s {
+	case StateSucceeded, StatePartiallyFailed, StateFailed, StateExpired:
+		return true
+	}
+	return false
+}
+
+// Record is the persisted status of a single job. Jobs split across several
+// queue messages also keep one Record per shard, identified by Shard.
+type Record struct {
+	JobID      string             `json:"jobId"`
+	Shard      int                `json:"shard,omitempty"`
+	ShardCount int                `json:"shardCount,omitempty"`
+	Status     State              `json:"status"`
+	Total      int                `json:"total"`
+	Processed  int                `json:"processed"`
+	Successful int                `json:"successful"`
+	Failed     int                `json:"failed"`
+	Summary    *converter.Summary `json:"summary,omitempty"`
+	Results    []converter.Result `json:"results,omitempty"` // Per-URL results, kept on shard records for archiving
+	Error      string             `json:"error,omitempty"`
+
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+}
+
+// NewRecord creates a queued record for a job converting total URLs.
+func NewRecord(jobID string, total int) *Record {
+	now := time.Now().UTC()
+	return &Record{
+		JobID:     jobID,
+		Status:    StateQueued,
+		Total:     total,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(DefaultRetention),
+	}
+}
+
+// NewShardRecord creates a queued record for one shard of a job.
+func NewShardRecord(jobID string, shard, shardCount, total int) *Record {
+	r := NewRecord(jobID, total)
+	r.Shard = shard
+	r.ShardCount = shardCount
+	return r
+}
+
+// Start marks the job as running and resets its progress counters.
+func (r *Record) Start() {
+	now := time.Now().UTC()
+	r.Status = StateRunning
+	r.Processed, r.Successful, r.Failed = 0, 0, 0
+	r.StartedAt = &now
+	r.UpdatedAt = now
+}
+
+// Observe records the outcome of a single URL conversion.
+func (r *Record) Observe(result converter.Result) {
+	r.Processed++
+	if result.IsSuccess {
+		r.Successful++
+	} else {
+		r.Failed++
+	}
+	r.UpdatedAt = time.Now().UTC()
+}
+
+// Finish completes the job from its conversion summary. The final state is
+// succeeded when every URL converted, failed when none did, and
+// partially_failed otherwise.
+func (r *Record) Finish(summary converter.Summary) {
+	now := time.Now().UTC()
+	r.Summary = &summary
+	r.Total = summary.TotalURLs
+	r.Processed = summary.Successful + summary.Failed + summary.Cancelled
+	r.Successful = summary.Successful
+	r.Failed = summary.Failed + summary.Cancelled
+
+	switch {
+	case summary.Successful == summary.TotalURLs:
+		r.Status = StateSucceeded
+	case summary.Successful == 0:
+		r.Status = StateFailed
+	default:
+		r.Status = StatePartiallyFailed
+	}
+	r.CompletedAt = &now
+	r.UpdatedAt = now
+}
+
+// Fail marks the whole job as failed with the given reason.
+func (r *Record) Fail(reason string) {
+	now := time.Now().UTC()
+	r.Status = StateFailed
+	r.Error = reason
+	r.CompletedAt = &now
+	r.UpdatedAt = now
+}
+
+// Requeue returns a job whose attempt failed transiently to the queued state,
+// keeping the reason so that it is visible while the job waits to be retried.
+func (r *Record) Requeue(reason string) {
+	r.Status = StateQueued
+	r.Error = reason
+	r.CompletedAt = nil
+	r.UpdatedAt = time.Now().UTC()
+}
+
+// Store persists job records as JSON files in a converter.Storage.
+type Store struct {
+	storage converter.Storage
+}
+
+// NewStore creates a Store that keeps records under "jobs/" in storage.
+func NewStore(storage converter.Storage) *Store {
+	return &Store{storage: storage}
+}
+
+// NewStoreFromEnv creates a Store in the output bucket named by the
+// OUTPUT_BUCKET_NAME and OBJECT_STORAGE_NAMESPACE environment variables.
+// When no bucket is configured, records are kept under the local tmp directory.
+func NewStoreFromEnv() (*Store, error) {
+	bucketName := os.Getenv("OUTPUT_BUCKET_NAME")
+	if bucketName == "" {
+		storage, err := converter.NewFileStorage(filepath.Join("tmp", "downloads"))
+		if err != nil {
+			return nil, err
+		}
+		return NewStore(storage), nil
+	}
+
+	storage, err := converter.NewObjectStorageFromEnv(os.Getenv("OBJECT_STORAGE_NAMESPACE"), bucketName, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(storage), nil
+}
+
+// Get loads the record for jobID. While a job is in progress, its counts and
+// state are aggregated from the shard records, since workers only update those;
+// a job that fits in one message has a single shard. Records past their
+// expiry are reported as expired. ErrNotFound is returned if the job does not exist.
+func (s *Store) Get(ctx context.Context, jobID string) (*Record, error) {
+	if err := jobid.Validate(jobID); err != nil {
+		return nil, err
+	}
+
+	record, err := s.read(ctx, recordName(jobID))
+	if err != nil {
+		return nil, err
+	}
+
+	if !record.Status.IsTerminal() && record.ShardCount >= 1 {
+		shards, err := s.Shards(ctx, jobID, record.ShardCount)
+		if err != nil {
+			return nil, err
+		}
+		record.aggregate(shards)
+	}
+
+	if !record.ExpiresAt.IsZero() && time.Now().After(record.ExpiresAt) {
+		record.Status = StateExpired
+	}
+	return record, nil
+}
+
+// Put saves the record, replacing any previous version.
+func (s *Store) Put(ctx context.Context, record *Record) error {
+	return s.write(ctx, recordName(record.JobID), record)
+}
+
+// PutShard saves the record of a single shard.
+func (s *Store) PutShard(ctx context.Context, record *Record) error {
+	return s.write(ctx, shardName(record.JobID, record.Shard), record)
+}
+
+// Shards loads the records of every shard of a job. Shards that have not been
+// picked up yet are returned as nil.
+func (s *Store) Shards(ctx context.Context, jobID string, count int) ([]*Record, error) {
+	if err := jobid.Validate(jobID); err != nil {
+		return nil, err
+	}
+
+	shards := make([]*Record, count)
+	for i := range shards {
+		record, err := s.read(ctx, shardName(jobID, i))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		shards[i] = record
+	}
+	return shards, nil
+}
+
+// ShardsComplete reports whether every shard has finished converting.
+func ShardsComplete(shards []*Record) bool {
+	for _, shard := range shards {
+		if shard == nil || shard.Summary == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// MergeShards combines the results and summaries of finished shards into the
+// results and summary of the whole job.
+func MergeShards(shards []*Record) ([]converter.Result, converter.Summary) {
+	var results []converter.Result
+	var merged converter.Summary
+	var start, end time.Time
+
+	for _, shard := range shards {
+		if shard == nil || shard.Summary == nil {
+			continue
+		}
+		results = append(results, shard.Results...)
+
+		summary := shard.Summary
+		merged.DownloadID = summary.DownloadID
+		merged.TotalURLs += summary.TotalURLs
+		merged.Successful += summary.Successful
+		merged.Failed += summary.Failed
+		merged.FailedURLs = append(merged.FailedURLs, summary.FailedURLs...)
+		merged.Cancelled += summary.Cancelled
+		merged.CancelledURLs = append(merged.CancelledURLs, summary.CancelledURLs...)
+
+		if shard.StartedAt != nil && (start.IsZero() || shard.StartedAt.Before(start)) {
+			start = *shard.StartedAt
+		}
+		if shard.CompletedAt != nil && shard.CompletedAt.After(end) {
+			end = *shard.CompletedAt
+		}
+	}
+
+	if !start.IsZero() && !end.IsZero() {
+		merged.ProcessingTime = end.Sub(start).String()
+	}
+	return results, merged
+}
+
+// aggregate folds the progress of the shard records into r.
+func (r *Record) aggregate(shards []*Record) {
+	processed, successful, failed := 0, 0, 0
+	started := false
+	for _, shard := range shards {
+		if shard == nil {
+			continue
+		}
+		processed += shard.Processed
+		successful += shard.Successful
+		failed += shard.Failed
+		if shard.StartedAt != nil {
+			started = true
+			if r.StartedAt == nil || shard.StartedAt.Before(*r.StartedAt) {
+				r.StartedAt = shard.StartedAt
+			}
+		}
+		if shard.UpdatedAt.After(r.UpdatedAt) {
+			r.UpdatedAt = shard.UpdatedAt
+		}
+	}
+
+	r.Processed, r.Successful, r.Failed = processed, successful, failed
+	if started {
+		r.Status = StateRunning
+	}
+}
+
+// read loads and decodes the record stored under name.
+func (s *Store) read(ctx context.Context, name string) (*Record, error) {
+	r, err := s.storage.Open(ctx, name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return &record, nil
+}
+
+// write encodes record and stores it under name.
+func (s *Store) write(ctx context.Context, name string, record *Record) error {
+	if err := jobid.Validate(record.JobID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode job %s: %w", record.JobID, err)
+	}
+	if err := s.storage.Put(ctx, name, data); err != nil {
+		return fmt.Errorf("failed to save job %s: %w", record.JobID, err)
+	}
+	return nil
+}
+
+// recordName returns the storage name of the record for jobID.
+func recordName(jobID string) string {
+	return "jobs/" + jobID + ".json"
+}
+
+// shardName returns the storage name of the record for one shard of jobID.
+func shardName(jobID string, shard int) string {
+	return fmt.Sprintf("jobs/%s/shard-%d.json", jobID, shard)
+}
@@ -0,0 +1,163 @@
+// Package ociauth selects how OCI SDK clients authenticate. Functions running
+// in OCI use resource principals, compute instances use instance principals,
+// and local runs read a profile from the OCI CLI configuration file.
+package ociauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+)
+
+// Supported values of the OCI_AUTH environment variable.
+const (
+	ResourcePrincipal = "resource_principal"
+	InstancePrincipal = "instance_principal"
+	ConfigFile        = "config_file"
+	Stub              = "stub" // Fake credentials for tests and offline runs
+)
+
+const (
+	defaultProfile    = "DEFAULT"
+	defaultStubRegion = "us-ashburn-1"
+)
+
+// ProviderFromEnv returns the configuration provider selected by OCI_AUTH.
+// When OCI_AUTH is unset, the method is detected from the environment:
+// resource principal when OCI_RESOURCE_PRINCIPAL_VERSION is set (as it is
+// inside OCI Functions), the config file when one exists, and the instance
+// principal otherwise.
+//
+// The config file method reads OCI_CONFIG_FILE (default ~/.oci/config),
+// the OCI_CONFIG_PROFILE profile (default DEFAULT) and the optional
+// OCI_KEY_PASSPHRASE.
+func ProviderFromEnv() (common.ConfigurationProvider, error) {
+	method := strings.ToLower(strings.TrimSpace(os.Getenv("OCI_AUTH")))
+	if method == "" {
+		method = detectMethod()
+	}
+	return NewProvider(method)
+}
+
+// NewProvider returns the configuration provider for the given method.
+func NewProvider(method string) (common.ConfigurationProvider, error) {
+	switch method {
+	case ResourcePrincipal:
+		provider, err := auth.ResourcePrincipalConfigurationProvider()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create resource principal provider: %w", err)
+		}
+		return provider, nil
+	case InstancePrincipal:
+		provider, err := auth.InstancePrincipalConfigurationProvider()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create instance principal provider: %w", err)
+		}
+		return provider, nil
+	case ConfigFile:
+		return configFileProvider()
+	case Stub:
+		return StubProvider()
+	default:
+		return nil, fmt.Errorf("unsupported OCI_AUTH method %q", method)
+	}
+}
+
+// detectMethod picks an authentication method when OCI_AUTH is not set.
+func detectMethod() string {
+	if os.Getenv("OCI_RESOURCE_PRINCIPAL_VERSION") != "" {
+		return ResourcePrincipal
+	}
+	if _, err := os.Stat(configFilePath()); err == nil {
+		return ConfigFile
+	}
+	return InstancePrincipal
+}
+
+// configFilePath returns the OCI CLI configuration file to read.
+func configFilePath() string {
+	if path := os.Getenv("OCI_CONFIG_FILE"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".oci", "config")
+	}
+	return filepath.Join(home, ".oci", "config")
+}
+
+// configFileProvider reads the configured profile from the OCI config file.
+func configFileProvider() (common.ConfigurationProvider, error) {
+	profile := os.Getenv("OCI_CONFIG_PROFILE")
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	path := configFilePath()
+	provider, err := common.ConfigurationProviderFromFileWithProfile(path, profile, os.Getenv("OCI_KEY_PASSPHRASE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile %s from %s: %w", profile, path, err)
+	}
+	if ok, err := common.IsConfigurationProviderValid(provider); !ok {
+		return nil, fmt.Errorf("invalid profile %s in %s: %w", profile, path, err)
+	}
+	return provider, nil
+}
+
+var (
+	stubKeyOnce sync.Once
+	stubKey     *rsa.PrivateKey
+	stubKeyErr  error
+)
+
+// StubProvider returns a provider with fake credentials and a throwaway
+// signing key. Clients built from it can be constructed without any OCI
+// setup, but every request they send will be rejected by OCI. The region is
+// taken from OCI_REGION, defaulting to us-ashburn-1.
+func StubProvider() (common.ConfigurationProvider, error) {
+	stubKeyOnce.Do(func() {
+		stubKey, stubKeyErr = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	if stubKeyErr != nil {
+		return nil, fmt.Errorf("failed to generate stub signing key: %w", stubKeyErr)
+	}
+
+	region := os.Getenv("OCI_REGION")
+	if region == "" {
+		region = defaultStubRegion
+	}
+	return stubProvider{region: region, key: stubKey}, nil
+}
+
+// stubProvider implements common.ConfigurationProvider with fixed values.
+type stubProvider struct {
+	region string
+	key    *rsa.PrivateKey
+}
+
+func (p stubProvider) PrivateRSAKey() (*rsa.PrivateKey, error) { return p.key, nil }
+
+func (p stubProvider) KeyID() (string, error) {
+	return "ocid1.tenancy.oc1..stub/ocid1.user.oc1..stub/00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00", nil
+}
+
+func (p stubProvider) TenancyOCID() (string, error) { return "ocid1.tenancy.oc1..stub", nil }
+
+func (p stubProvider) UserOCID() (string, error) { return "ocid1.user.oc1..stub", nil }
+
+func (p stubProvider) KeyFingerprint() (string, error) {
+	return "00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00", nil
+}
+
+func (p stubProvider) Region() (string, error) { return p.region, nil }
+
+func (p stubProvider) AuthType() (common.AuthConfig, error) {
+	return common.AuthConfig{AuthType: common.UserPrincipal}, nil
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxAttempts is the number of times a job is attempted before it is
+// moved to the dead-letter queue.
+const DefaultMaxAttempts = 3
+
+// RetryPolicy decides when a failing job is given up on.
+type RetryPolicy struct {
+	MaxAttempts int
+}
+
+// RetryPolicyFromEnv reads MAX_JOB_ATTEMPTS, falling back to DefaultMaxAttempts.
+func RetryPolicyFromEnv() RetryPolicy {
+	if n, err := strconv.Atoi(os.Getenv("MAX_JOB_ATTEMPTS")); err == nil && n > 0 {
+		return RetryPolicy{MaxAttempts: n}
+	}
+	return RetryPolicy{MaxAttempts: DefaultMaxAttempts}
+}
+
+// Exhausted reports whether a job that has been attempted attempts times
+// should not be retried again.
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return attempts >= maxAttempts
+}
+
+// Attempts returns how many times the job in msg has been attempted, counting
+// the current delivery. Redeliveries caused by a crashed consumer are included
+// through the message's delivery count.
+func Attempts(job *ConversionJob, msg Message) int {
+	deliveries := max(msg.DeliveryCount, 1)
+	if job == nil {
+		return deliveries
+	}
+	return job.Attempts + deliveries
+}
+
+// Retry republishes job with its attempt counter set to attempts. The caller
+// acknowledges the original message once Retry succeeds.
+func Retry(ctx context.Context, q JobQueue, job *ConversionJob, attempts int, reason string) error {
+	retry := *job
+	retry.Attempts = attempts
+	retry.LastError = reason
+	return q.Put(ctx, &retry)
+}
+
+// DeadLetter publishes a failed message to the dead-letter queue with the
+// failure reason attached. job may be nil when the message could not be decoded;
+// the raw content is kept so that it can still be inspected.
+func DeadLetter(ctx context.Context, dlq JobQueue, job *ConversionJob, msg Message, attempts int, reason string) error {
+	var entry ConversionJob
+	if job != nil {
+		entry = *job
+	} else {
+		entry.RawMessage = msg.Content
+	}
+	entry.Attempts = attempts
+	entry.LastError = reason
+	entry.DeadLetteredAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := dlq.Put(ctx, &entry); err != nil {
+		return fmt.Errorf("failed to dead-letter message %s: %w", msg.ID, err)
+	}
+	return nil
+}
+
+// Requeue moves a dead-lettered job back onto the main queue with a fresh
+// attempt counter and acknowledges it on the dead-letter queue. Entries that
+// were dead-lettered because they could not be decoded cannot be requeued.
+func Requeue(ctx context.Context, dlq, q JobQueue, msg Message) error {
+	job, err := msg.Job()
+	if err != nil {
+		return err
+	}
+	if job.RawMessage != "" {
+		return fmt.Errorf("message %s holds an undecodable job and cannot be requeued", msg.ID)
+	}
+
+	job.Attempts = 0
+	job.LastError = ""
+	job.DeadLetteredAt = ""
+	if err := q.Put(ctx, job); err != nil {
+		return fmt.Errorf("failed to requeue job %s: %w", job.DownloadID, err)
+	}
+	return dlq.Ack(ctx, msg.Receipt)
+}
+
+// NewDeadLetterFromEnv creates the dead-letter JobQueue configured by the
+// environment: the OCI queue named by DLQ_OCID, or else a file-backed queue in
+// LOCAL_DLQ_DIR. It returns nil, nil if no dead-letter queue is configured.
+func NewDeadLetterFromEnv() (JobQueue, error) {
+	if queueID := os.Getenv("DLQ_OCID"); queueID != "" {
+		client, err := NewOCIQueueClient(queueID)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	if dir := os.Getenv("LOCAL_DLQ_DIR"); dir != "" {
+		q, err := NewFileQueue(dir)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+const (
+	fileQueueName = "queue.json"
+	fileLockName  = "queue.lock"
+)
+
+// FileQueue is a JobQueue persisted in a directory on the local filesystem. It
+// can be shared by several processes on the same machine, which makes it
+// suitable for running the functions locally.
+type FileQueue struct {
+	dir string
+	// mu serializes the goroutines of this process; lock only excludes other
+	// processes, since a Flock reports success when it is already held.
+	mu   sync.Mutex
+	lock *flock.Flock
+}
+
+// NewFileQueue creates a FileQueue in dir, creating the directory if needed.
+func NewFileQueue(dir string) (*FileQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory %s: %w", dir, err)
+	}
+	return &FileQueue{
+		dir:  dir,
+		lock: flock.New(filepath.Join(dir, fileLockName)),
+	}, nil
+}
+
+// Put publishes a new job.
+func (q *FileQueue) Put(ctx context.Context, job *ConversionJob) error {
+	return q.PutBatch(ctx, []*ConversionJob{job})
+}
+
+// PutBatch publishes several jobs atomically.
+func (q *FileQueue) PutBatch(ctx context.Context, jobs []*ConversionJob) error {
+	bodies, err := encodeJobs(jobs)
+	if err != nil {
+		return err
+	}
+	return q.update(ctx, func(s *queueState) error {
+		for _, body := range bodies {
+			s.put(body)
+		}
+		return nil
+	})
+}
+
+// Receive returns up to limit visible messages.
+func (q *FileQueue) Receive(ctx context.Context, limit int, visibility time.Duration) ([]Message, error) {
+	var out []Message
+	err := q.update(ctx, func(s *queueState) error {
+		out = s.receive(limit, visibility)
+		return nil
+	})
+	return out, err
+}
+
+// Ack removes a received message.
+func (q *FileQueue) Ack(ctx context.Context, receipt string) error {
+	return q.update(ctx, func(s *queueState) error {
+		return s.ack(receipt)
+	})
+}
+
+// Nack makes a received message visible again immediately.
+func (q *FileQueue) Nack(ctx context.Context, receipt string) error {
+	return q.update(ctx, func(s *queueState) error {
+		return s.setVisibility(receipt, 0)
+	})
+}
+
+// ExtendVisibility hides a received message for d from now.
+func (q *FileQueue) ExtendVisibility(ctx context.Context, receipt string, d time.Duration) error {
+	return q.update(ctx, func(s *queueState) error {
+		return s.setVisibility(receipt, d)
+	})
+}
+
+// update loads the queue state under an exclusive file lock, applies fn and
+// saves the result if fn succeeds.
+func (q *FileQueue) update(ctx context.Context, fn func(*queueState) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	locked, err := q.lock.TryLockContext(ctx, 10*time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("failed to lock queue: %w", err)
+	}
+	if !locked {
+		return fmt.Errorf("failed to lock queue")
+	}
+	defer q.lock.Unlock()
+
+	path := filepath.Join(q.dir, fileQueueName)
+
+	var state queueState
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read queue: %w", err)
+	default:
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to decode queue: %w", err)
+		}
+	}
+
+	if err := fn(&state); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode queue: %w", err)
+	}
+
+	// Write to a temporary file and rename so readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write queue: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
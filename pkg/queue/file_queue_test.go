@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileQueueConcurrentPut(t *testing.T) {
+	q, err := NewFileQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const jobs = 50
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := q.Put(ctx, &ConversionJob{URLs: []string{"https://example.com/"}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	messages, err := q.Receive(ctx, 2*jobs, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != jobs {
+		t.Fatalf("received %d messages, want %d", len(messages), jobs)
+	}
+}
@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryQueue is an in-process JobQueue for tests and local development.
+type MemoryQueue struct {
+	mu    sync.Mutex
+	state queueState
+}
+
+// NewMemoryQueue creates an empty MemoryQueue.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{}
+}
+
+// Put publishes a new job.
+func (q *MemoryQueue) Put(ctx context.Context, job *ConversionJob) error {
+	return q.PutBatch(ctx, []*ConversionJob{job})
+}
+
+// PutBatch publishes several jobs atomically.
+func (q *MemoryQueue) PutBatch(ctx context.Context, jobs []*ConversionJob) error {
+	bodies, err := encodeJobs(jobs)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, body := range bodies {
+		q.state.put(body)
+	}
+	return nil
+}
+
+// Receive returns up to limit visible messages.
+func (q *MemoryQueue) Receive(ctx context.Context, limit int, visibility time.Duration) ([]Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.state.receive(limit, visibility), nil
+}
+
+// Ack removes a received message.
+func (q *MemoryQueue) Ack(ctx context.Context, receipt string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.state.ack(receipt)
+}
+
+// Nack makes a received message visible again immediately.
+func (q *MemoryQueue) Nack(ctx context.Context, receipt string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.state.setVisibility(receipt, 0)
+}
+
+// ExtendVisibility hides a received message for d from now.
+func (q *MemoryQueue) ExtendVisibility(ctx context.Context, receipt string, d time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.state.setVisibility(receipt, d)
+}
+
+// Len returns the number of messages in the queue, including invisible ones.
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.state.Messages)
+}
+
+// encodeJobs marshals each job to its message content.
+func encodeJobs(jobs []*ConversionJob) ([]string, error) {
+	bodies := make([]string, len(jobs))
+	for i, job := range jobs {
+		body, err := json.Marshal(job)
+		if err != nil {
+			return nil, err
+		}
+		bodies[i] = string(body)
+	}
+	return bodies, nil
+}
+
+// queueState is the queue model shared by MemoryQueue and FileQueue. It is not
+// safe for concurrent use; callers provide their own locking.
+type queueState struct {
+	NextID   int64           `json:"nextId"`
+	Messages []storedMessage `json:"messages"`
+}
+
+// storedMessage is a message together with its delivery bookkeeping.
+type storedMessage struct {
+	ID            int64     `json:"id"`
+	Content       string    `json:"content"`
+	VisibleAt     time.Time `json:"visibleAt"`
+	DeliveryCount int       `json:"deliveryCount"`
+}
+
+// receipt identifies the current delivery of the message. It changes on every
+// delivery so that a consumer whose visibility timeout expired cannot ack a
+// message that has since been handed to someone else.
+func (m *storedMessage) receipt() string {
+	return fmt.Sprintf("%d.%d", m.ID, m.DeliveryCount)
+}
+
+func (s *queueState) put(content string) {
+	s.NextID++
+	s.Messages = append(s.Messages, storedMessage{
+		ID:        s.NextID,
+		Content:   content,
+		VisibleAt: time.Now(),
+	})
+}
+
+func (s *queueState) receive(limit int, visibility time.Duration) []Message {
+	now := time.Now()
+	var out []Message
+	for i := range s.Messages {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		m := &s.Messages[i]
+		if m.VisibleAt.After(now) {
+			continue
+		}
+
+		m.DeliveryCount++
+		m.VisibleAt = now.Add(visibility)
+		out = append(out, Message{
+			ID:            strconv.FormatInt(m.ID, 10),
+			Receipt:       m.receipt(),
+			DeliveryCount: m.DeliveryCount,
+			Content:       m.Content,
+		})
+	}
+	return out
+}
+
+func (s *queueState) ack(receipt string) error {
+	i, err := s.find(receipt)
+	if err != nil {
+		return err
+	}
+	s.Messages = append(s.Messages[:i], s.Messages[i+1:]...)
+	return nil
+}
+
+func (s *queueState) setVisibility(receipt string, d time.Duration) error {
+	i, err := s.find(receipt)
+	if err != nil {
+		return err
+	}
+	s.Messages[i].VisibleAt = time.Now().Add(d)
+	return nil
+}
+
+// find returns the index of the in-flight message matching receipt.
+func (s *queueState) find(receipt string) (int, error) {
+	id, _, ok := strings.Cut(receipt, ".")
+	if !ok {
+		return -1, ErrInvalidReceipt
+	}
+
+	now := time.Now()
+	for i := range s.Messages {
+		m := &s.Messages[i]
+		if strconv.FormatInt(m.ID, 10) != id {
+			continue
+		}
+		if m.receipt() != receipt || !m.VisibleAt.After(now) {
+			return -1, ErrInvalidReceipt
+		}
+		return i, nil
+	}
+	return -1, ErrInvalidReceipt
+}
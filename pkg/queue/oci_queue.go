@@ -2,14 +2,23 @@ package queue
 
 import (
 	"context"
+	"doc-converter-oci-serverless/pkg/converter"
+	"doc-converter-oci-serverless/pkg/ociauth"
 	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
 
-	// Import the auth package
 	"github.com/oracle/oci-go-sdk/v65/common"
-	"github.com/oracle/oci-go-sdk/v65/common/auth" // <-- Add this import
 	"github.com/oracle/oci-go-sdk/v65/queue"
 )
 
+// Limits of a single OCI Queue PutMessages call.
+const (
+	maxBatchMessages = 20
+	maxBatchBytes    = 512 * 1024
+)
+
 // OCIQueueClient holds the client and queue ID
 type OCIQueueClient struct {
 	client  queue.QueueClient
@@ -19,15 +28,26 @@ type OCIQueueClient struct {
 // ConversionJob defines the structure for a job message.
 // This struct remains the same as before.
 type ConversionJob struct {
-	URLs       []string `json:"urls"`
-	Selector   string   `json:"selector"`
-	DownloadID string   `json:"downloadId"`
+	URLs       []string                     `json:"urls"`
+	Selector   string                       `json:"selector"`
+	DownloadID string                       `json:"downloadId"`
+	Options    *converter.ConversionOptions `json:"options,omitempty"` // Optional per-job tuning
+
+	// Jobs too large for one message are split into shards sharing the DownloadID
+	Shard      int `json:"shard,omitempty"`      // Zero-based index of this shard
+	ShardCount int `json:"shardCount,omitempty"` // Total number of shards; 0 for unsharded jobs
+
+	// Retry and dead-letter bookkeeping
+	Attempts       int    `json:"attempts,omitempty"`       // Attempts made by earlier deliveries
+	LastError      string `json:"lastError,omitempty"`      // Reason the last attempt failed
+	DeadLetteredAt string `json:"deadLetteredAt,omitempty"` // Set on entries in the dead-letter queue
+	RawMessage     string `json:"rawMessage,omitempty"`     // Original content of an undecodable dead-lettered message
 }
 
 // NewOCIQueueClient creates a new client to interact with OCI Queues.
+// It authenticates with the provider selected by ociauth.ProviderFromEnv.
 func NewOCIQueueClient(queueID string) (*OCIQueueClient, error) {
-	// FIX: Use the InstancePrincipalConfigurationProvider from the auth package
-	provider, err := auth.InstancePrincipalConfigurationProvider()
+	provider, err := ociauth.ProviderFromEnv()
 	if err != nil {
 		return nil, err
 	}
@@ -43,25 +63,147 @@ func NewOCIQueueClient(queueID string) (*OCIQueueClient, error) {
 	}, nil
 }
 
-// PutMessage publishes a new job to the OCI Queue.
-// This function remains the same as before.
-func (c *OCIQueueClient) PutMessage(job *ConversionJob) error {
-	body, err := json.Marshal(job)
+// Put publishes a new job to the OCI Queue.
+func (c *OCIQueueClient) Put(ctx context.Context, job *ConversionJob) error {
+	return c.PutBatch(ctx, []*ConversionJob{job})
+}
+
+// PutBatch publishes jobs to the OCI Queue, grouping them into as few
+// PutMessages calls as the service limits allow. Each call is atomic, so when
+// one fails, the jobs sent by the calls before it stay published.
+func (c *OCIQueueClient) PutBatch(ctx context.Context, jobs []*ConversionJob) (err error) {
+	var entries []queue.PutMessagesDetailsEntry
+	size, published := 0, 0
+	defer func() {
+		if err != nil && published > 0 {
+			err = &PartialPublishError{Published: published, Err: err}
+		}
+	}()
+
+	flush := func() error {
+		if len(entries) == 0 {
+			return nil
+		}
+		req := queue.PutMessagesRequest{
+			QueueId: &c.queueID,
+			PutMessagesDetails: queue.PutMessagesDetails{
+				Messages: entries,
+			},
+		}
+		if _, err := c.client.PutMessages(ctx, req); err != nil {
+			return err
+		}
+		published += len(entries)
+		entries, size = nil, 0
+		return nil
+	}
+
+	for _, job := range jobs {
+		body, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		if len(entries) >= maxBatchMessages || size+len(body) > maxBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, queue.PutMessagesDetailsEntry{
+			Content: common.String(string(body)),
+		})
+		size += len(body)
+	}
+	return flush()
+}
+
+// Receive fetches up to limit visible messages from the OCI Queue.
+func (c *OCIQueueClient) Receive(ctx context.Context, limit int, visibility time.Duration) ([]Message, error) {
+	req := queue.GetMessagesRequest{
+		QueueId:             &c.queueID,
+		VisibilityInSeconds: common.Int(visibilitySeconds(visibility)),
+		TimeoutInSeconds:    common.Int(0),
+	}
+	if limit > 0 {
+		req.Limit = common.Int(limit)
+	}
+
+	resp, err := c.client.GetMessages(ctx, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req := queue.PutMessagesRequest{
-		QueueId: &c.queueID,
-		PutMessagesDetails: queue.PutMessagesDetails{
-			Messages: []queue.PutMessagesDetailsEntry{
-				{
-					Content: common.String(string(body)),
-				},
-			},
-		},
+	messages := make([]Message, 0, len(resp.Messages))
+	for _, m := range resp.Messages {
+		messages = append(messages, Message{
+			ID:            strconv.FormatInt(derefInt64(m.Id), 10),
+			Receipt:       derefString(m.Receipt),
+			DeliveryCount: derefInt(m.DeliveryCount),
+			Content:       derefString(m.Content),
+		})
 	}
+	return messages, nil
+}
 
-	_, err = c.client.PutMessages(context.Background(), req)
+// Ack deletes a received message from the OCI Queue.
+func (c *OCIQueueClient) Ack(ctx context.Context, receipt string) error {
+	_, err := c.client.DeleteMessage(ctx, queue.DeleteMessageRequest{
+		QueueId:        &c.queueID,
+		MessageReceipt: common.String(receipt),
+	})
+	return translateReceiptError(err)
+}
+
+// Nack makes a received message visible again immediately.
+func (c *OCIQueueClient) Nack(ctx context.Context, receipt string) error {
+	return c.updateVisibility(ctx, receipt, 0)
+}
+
+// ExtendVisibility hides a received message for d from now.
+func (c *OCIQueueClient) ExtendVisibility(ctx context.Context, receipt string, d time.Duration) error {
+	return c.updateVisibility(ctx, receipt, visibilitySeconds(d))
+}
+
+func (c *OCIQueueClient) updateVisibility(ctx context.Context, receipt string, seconds int) error {
+	_, err := c.client.UpdateMessage(ctx, queue.UpdateMessageRequest{
+		QueueId:        &c.queueID,
+		MessageReceipt: common.String(receipt),
+		UpdateMessageDetails: queue.UpdateMessageDetails{
+			VisibilityInSeconds: common.Int(seconds),
+		},
+	})
+	return translateReceiptError(err)
+}
+
+// translateReceiptError maps the service's "receipt not found" response to ErrInvalidReceipt.
+func translateReceiptError(err error) error {
+	if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+		return ErrInvalidReceipt
+	}
 	return err
 }
+
+// visibilitySeconds rounds a visibility timeout up to whole seconds.
+func visibilitySeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func derefInt64(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
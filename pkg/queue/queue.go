@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrInvalidReceipt is returned when a receipt does not match a message that is
+// currently being processed, for example because its visibility timeout expired
+// and the message was delivered again.
+var ErrInvalidReceipt = errors.New("invalid or expired message receipt")
+
+// PartialPublishError is returned by PutBatch when it failed after the first
+// Published jobs had already been published. Queues whose batches are atomic
+// never return it.
+type PartialPublishError struct {
+	Published int
+	Err       error
+}
+
+// Error implements the error interface.
+func (e *PartialPublishError) Error() string {
+	return fmt.Sprintf("published %d jobs before failing: %v", e.Published, e.Err)
+}
+
+// Unwrap returns the error that stopped the batch.
+func (e *PartialPublishError) Unwrap() error {
+	return e.Err
+}
+
+// JobQueue is a queue of conversion jobs with at-least-once delivery. A received
+// message stays invisible to other consumers until its visibility timeout expires,
+// it is acknowledged, or it is released with Nack.
+type JobQueue interface {
+	// Put publishes a new job.
+	Put(ctx context.Context, job *ConversionJob) error
+	// PutBatch publishes several jobs in order, using as few requests as
+	// possible. If it fails after some of the jobs were published, the error is
+	// a *PartialPublishError.
+	PutBatch(ctx context.Context, jobs []*ConversionJob) error
+	// Receive returns up to limit visible messages, hiding each of them for the
+	// given visibility timeout. It does not wait for messages to arrive.
+	Receive(ctx context.Context, limit int, visibility time.Duration) ([]Message, error)
+	// Ack permanently removes a received message.
+	Ack(ctx context.Context, receipt string) error
+	// Nack makes a received message visible again immediately.
+	Nack(ctx context.Context, receipt string) error
+	// ExtendVisibility hides a received message for d from now.
+	ExtendVisibility(ctx context.Context, receipt string, d time.Duration) error
+}
+
+var (
+	_ JobQueue = (*OCIQueueClient)(nil)
+	_ JobQueue = (*MemoryQueue)(nil)
+	_ JobQueue = (*FileQueue)(nil)
+)
+
+// Message is a job message delivered by a JobQueue.
+type Message struct {
+	ID            string
+	Receipt       string
+	DeliveryCount int
+	Content       string
+}
+
+// Job decodes the message content into a ConversionJob.
+func (m Message) Job() (*ConversionJob, error) {
+	var job ConversionJob
+	if err := json.Unmarshal([]byte(m.Content), &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job from message %s: %w", m.ID, err)
+	}
+	return &job, nil
+}
+
+// NewFromEnv creates the JobQueue configured by the environment: the OCI queue
+// named by QUEUE_OCID, or else a file-backed queue in LOCAL_QUEUE_DIR.
+func NewFromEnv() (JobQueue, error) {
+	if queueID := os.Getenv("QUEUE_OCID"); queueID != "" {
+		client, err := NewOCIQueueClient(queueID)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	if dir := os.Getenv("LOCAL_QUEUE_DIR"); dir != "" {
+		q, err := NewFileQueue(dir)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	}
+	return nil, fmt.Errorf("no queue configured: set QUEUE_OCID or LOCAL_QUEUE_DIR")
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxShardURLs is the maximum number of URLs carried by a single job message.
+	MaxShardURLs = 100
+	// MaxMessageBytes is the maximum encoded size of a single job message. It is
+	// kept well below the OCI Queue message limit to leave room for encoding overhead.
+	MaxMessageBytes = 64 * 1024
+)
+
+// ErrJobTooLarge is returned by Split when a job cannot be divided into
+// messages of at most MaxMessageBytes, because the fields every shard carries,
+// such as the options, leave no room for its URLs.
+var ErrJobTooLarge = errors.New("job is too large for a queue message")
+
+// Split divides a job into shards that each fit in a single queue message,
+// holding at most MaxShardURLs URLs and MaxMessageBytes of encoded JSON. All
+// shards share the parent DownloadID and are numbered 0..ShardCount-1. A job
+// that already fits is returned as a single shard.
+func Split(job *ConversionJob) ([]*ConversionJob, error) {
+	base := *job
+	base.URLs = nil
+	// Size the shared fields with the largest shard numbers a shard can carry
+	base.Shard, base.ShardCount = len(job.URLs), len(job.URLs)
+	overhead := encodedSize(&base)
+	base.Shard, base.ShardCount = 0, 0
+
+	if overhead >= MaxMessageBytes {
+		return nil, fmt.Errorf("%w: the options and other fields shared by every message take %d bytes, more than the %d allowed",
+			ErrJobTooLarge, overhead, MaxMessageBytes)
+	}
+
+	var groups [][]string
+	var current []string
+	size := overhead
+	for i, u := range job.URLs {
+		encoded, _ := json.Marshal(u)
+		urlSize := len(encoded) + 1 // plus the separating comma
+		if overhead+urlSize > MaxMessageBytes {
+			return nil, fmt.Errorf("%w: URL %d does not fit in a message alongside the job's options", ErrJobTooLarge, i+1)
+		}
+		if len(current) > 0 && (len(current) >= MaxShardURLs || size+urlSize > MaxMessageBytes) {
+			groups = append(groups, current)
+			current, size = nil, overhead
+		}
+		current = append(current, u)
+		size += urlSize
+	}
+	if len(current) > 0 || len(groups) == 0 {
+		groups = append(groups, current)
+	}
+
+	shards := make([]*ConversionJob, len(groups))
+	for i, urls := range groups {
+		shard := base
+		shard.URLs = urls
+		shard.Shard = i
+		shard.ShardCount = len(groups)
+		shards[i] = &shard
+	}
+	return shards, nil
+}
+
+// Publish splits a job into shards and publishes them with batched puts. It
+// returns the shards that were published, which are the leading ones if
+// PutBatch fails with a *PartialPublishError.
+func Publish(ctx context.Context, q JobQueue, job *ConversionJob) ([]*ConversionJob, error) {
+	shards, err := Split(job)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.PutBatch(ctx, shards); err != nil {
+		var partial *PartialPublishError
+		if errors.As(err, &partial) {
+			return shards[:partial.Published], err
+		}
+		return nil, err
+	}
+	return shards, nil
+}
+
+// ShardCountOf returns the number of shards the job's parent was split into,
+// treating jobs published before sharding existed as a single shard.
+func ShardCountOf(job *ConversionJob) int {
+	return max(job.ShardCount, 1)
+}
+
+func encodedSize(job *ConversionJob) int {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return 0
+	}
+	return len(body)
+}
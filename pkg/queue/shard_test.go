@@ -0,0 +1,122 @@
+package queue
+
+import (
+	"context"
+	"doc-converter-oci-serverless/pkg/converter"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	urls := func(n, length int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("https://example.com/%0*d", length, i)
+		}
+		return list
+	}
+	hugeFields := map[string]interface{}{"notes": strings.Repeat("x", MaxMessageBytes)}
+
+	tests := []struct {
+		name    string
+		job     ConversionJob
+		shards  int
+		tooLong bool
+	}{
+		{"single URL", ConversionJob{URLs: urls(1, 10)}, 1, false},
+		{"at the URL limit", ConversionJob{URLs: urls(MaxShardURLs, 10)}, 1, false},
+		{"above the URL limit", ConversionJob{URLs: urls(MaxShardURLs+1, 10)}, 2, false},
+		{"many URLs", ConversionJob{URLs: urls(1050, 10)}, 11, false},
+		{"long URLs", ConversionJob{URLs: urls(60, 2000)}, 2, false},
+		{
+			"large options",
+			ConversionJob{URLs: urls(10, 500), Options: &converter.ConversionOptions{
+				FrontMatter: converter.FrontMatterOptions{Fields: map[string]interface{}{"notes": strings.Repeat("x", MaxMessageBytes-2000)}},
+			}},
+			4, false,
+		},
+		{
+			"options larger than a message",
+			ConversionJob{URLs: urls(10, 10), Options: &converter.ConversionOptions{
+				FrontMatter: converter.FrontMatterOptions{Fields: hugeFields},
+			}},
+			0, true,
+		},
+		{"URL larger than a message", ConversionJob{URLs: []string{"https://example.com/" + strings.Repeat("a", MaxMessageBytes)}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.job.DownloadID = "0190a6c1-7e3b-7000-8000-000000000000"
+			shards, err := Split(&tt.job)
+			if tt.tooLong {
+				if !errors.Is(err, ErrJobTooLarge) {
+					t.Fatalf("Split() = %d shards, %v, want ErrJobTooLarge", len(shards), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shards) != tt.shards {
+				t.Fatalf("Split() = %d shards, want %d", len(shards), tt.shards)
+			}
+
+			var total int
+			for i, shard := range shards {
+				if size := encodedSize(shard); size > MaxMessageBytes {
+					t.Errorf("shard %d is %d bytes, more than %d", i, size, MaxMessageBytes)
+				}
+				if shard.Shard != i || shard.ShardCount != len(shards) || shard.DownloadID != tt.job.DownloadID {
+					t.Errorf("shard %d is numbered %d/%d for job %s", i, shard.Shard, shard.ShardCount, shard.DownloadID)
+				}
+				total += len(shard.URLs)
+			}
+			if total != len(tt.job.URLs) {
+				t.Fatalf("shards hold %d URLs, want %d", total, len(tt.job.URLs))
+			}
+		})
+	}
+}
+
+// failingQueue publishes the first limit jobs of a batch and then fails.
+type failingQueue struct {
+	*MemoryQueue
+	limit int
+}
+
+func (q failingQueue) PutBatch(ctx context.Context, jobs []*ConversionJob) error {
+	if len(jobs) <= q.limit {
+		return q.MemoryQueue.PutBatch(ctx, jobs)
+	}
+	if err := q.MemoryQueue.PutBatch(ctx, jobs[:q.limit]); err != nil {
+		return err
+	}
+	err := errors.New("service unavailable")
+	if q.limit > 0 {
+		return &PartialPublishError{Published: q.limit, Err: err}
+	}
+	return err
+}
+
+func TestPublishPartial(t *testing.T) {
+	job := &ConversionJob{DownloadID: "0190a6c1-7e3b-7000-8000-000000000000"}
+	for i := 0; i < 3*MaxShardURLs; i++ {
+		job.URLs = append(job.URLs, fmt.Sprintf("https://example.com/%d", i))
+	}
+
+	published, err := Publish(context.Background(), failingQueue{NewMemoryQueue(), 2}, job)
+	var partial *PartialPublishError
+	if !errors.As(err, &partial) || partial.Published != 2 {
+		t.Fatalf("Publish() error = %v, want a partial publish of 2 shards", err)
+	}
+	if len(published) != 2 || published[0].Shard != 0 || published[1].Shard != 1 {
+		t.Fatalf("Publish() returned %d shards, want shards 0 and 1", len(published))
+	}
+
+	published, err = Publish(context.Background(), failingQueue{NewMemoryQueue(), 0}, job)
+	if err == nil || published != nil {
+		t.Fatalf("Publish() = %d shards, %v, want no shards and an error", len(published), err)
+	}
+}
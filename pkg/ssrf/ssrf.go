@@ -0,0 +1,387 @@
+// Package ssrf decides which destinations the converter may fetch pages from.
+// A Policy blocks every address that is not globally routable, including
+// private and shared address space, cloud metadata endpoints, documentation
+// and benchmarking ranges, multicast, and IPv6 forms that embed such an IPv4
+// address. Deployments can permit or block further ranges, and restrict the
+// schemes and ports that may be used.
+package ssrf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// MaxRedirects is the number of redirects followed for a single request.
+const MaxRedirects = 10
+
+// ErrBlocked is returned, wrapped, for every destination a Policy refuses.
+var ErrBlocked = errors.New("destination blocked by SSRF policy")
+
+// blockedRanges are the address ranges that are never fetched unless
+// explicitly allowed. IPv4-mapped IPv6 addresses are checked as IPv4, and
+// NAT64 and 6to4 addresses by the IPv4 address they embed.
+var blockedRanges = []netip.Prefix{
+	// IPv4
+	netip.MustParsePrefix("0.0.0.0/8"),          // "This network", including 0.0.0.0
+	netip.MustParsePrefix("10.0.0.0/8"),         // Private
+	netip.MustParsePrefix("100.64.0.0/10"),      // Shared address space (CGNAT)
+	netip.MustParsePrefix("127.0.0.0/8"),        // Loopback
+	netip.MustParsePrefix("169.254.0.0/16"),     // Link-local, including the 169.254.169.254 metadata endpoint
+	netip.MustParsePrefix("172.16.0.0/12"),      // Private
+	netip.MustParsePrefix("192.0.0.0/24"),       // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),       // Documentation (TEST-NET-1)
+	netip.MustParsePrefix("192.88.99.0/24"),     // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),     // Private
+	netip.MustParsePrefix("198.18.0.0/15"),      // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"),    // Documentation (TEST-NET-2)
+	netip.MustParsePrefix("203.0.113.0/24"),     // Documentation (TEST-NET-3)
+	netip.MustParsePrefix("224.0.0.0/4"),        // Multicast
+	netip.MustParsePrefix("240.0.0.0/4"),        // Reserved, including broadcast
+	netip.MustParsePrefix("255.255.255.255/32"), // Limited broadcast
+
+	// IPv6
+	netip.MustParsePrefix("::/128"),         // Unspecified
+	netip.MustParsePrefix("::1/128"),        // Loopback
+	netip.MustParsePrefix("::/96"),          // IPv4-compatible (deprecated)
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+	netip.MustParsePrefix("100::/64"),       // Discard-only
+	netip.MustParsePrefix("2001::/32"),      // Teredo
+	netip.MustParsePrefix("2001:10::/28"),   // ORCHID
+	netip.MustParsePrefix("2001:20::/28"),   // ORCHIDv2
+	netip.MustParsePrefix("2001:db8::/32"),  // Documentation
+	netip.MustParsePrefix("3fff::/20"),      // Documentation
+	netip.MustParsePrefix("fc00::/7"),       // Unique local, including the OCI fd00:c1::a9fe:a9fe metadata endpoint
+	netip.MustParsePrefix("fe80::/10"),      // Link-local
+	netip.MustParsePrefix("fec0::/10"),      // Site-local (deprecated)
+	netip.MustParsePrefix("ff00::/8"),       // Multicast
+}
+
+var (
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour   = netip.MustParsePrefix("2002::/16")
+)
+
+// Validator decides which URLs may be requested and which connections may be
+// made. *Policy is the standard implementation.
+type Validator interface {
+	// CheckURL is called before a URL is requested and for every redirect target.
+	CheckURL(u *url.URL) error
+	// CheckDial is called for every connection with the resolved address being dialled.
+	CheckDial(network string, addr netip.AddrPort) error
+}
+
+// Control returns a net.Dialer Control hook validating every connection with
+// v. It runs after DNS resolution, so a host name cannot pass a check and then
+// resolve to a blocked address.
+func Control(v Validator) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		addr, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: invalid dial address %q", ErrBlocked, address)
+		}
+		return v.CheckDial(network, addr)
+	}
+}
+
+// CheckRedirect returns an http.Client redirect policy applying v.CheckURL to
+// every hop and following at most MaxRedirects redirects.
+func CheckRedirect(v Validator) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+		}
+		if err := v.CheckURL(req.URL); err != nil {
+			return fmt.Errorf("redirect to %s: %w", req.URL.Redacted(), err)
+		}
+		return nil
+	}
+}
+
+// AllowAddrPorts returns a Validator that permits exactly the given addresses,
+// such as the listeners of httptest servers, and defers to base for everything else.
+func AllowAddrPorts(base Validator, addrs ...netip.AddrPort) Validator {
+	allowed := make(map[netip.AddrPort]bool, len(addrs))
+	for _, addr := range addrs {
+		allowed[netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())] = true
+	}
+	return &addrPortAllowlist{base: base, allowed: allowed}
+}
+
+// addrPortAllowlist is the Validator returned by AllowAddrPorts.
+type addrPortAllowlist struct {
+	base    Validator
+	allowed map[netip.AddrPort]bool
+}
+
+func (a *addrPortAllowlist) CheckURL(u *url.URL) error {
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		if port, err := urlPort(u); err == nil && a.allowed[netip.AddrPortFrom(addr.Unmap(), port)] {
+			return nil
+		}
+	}
+	return a.base.CheckURL(u)
+}
+
+func (a *addrPortAllowlist) CheckDial(network string, addr netip.AddrPort) error {
+	if a.allowed[netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())] {
+		return nil
+	}
+	return a.base.CheckDial(network, addr)
+}
+
+// Policy decides which URLs and addresses may be connected to. The zero value
+// blocks the built-in ranges and allows every scheme and port.
+type Policy struct {
+	// AllowedSchemes lists the URL schemes that may be fetched. Empty allows any.
+	AllowedSchemes []string
+	// AllowedPorts lists the destination ports that may be connected to. Empty allows any.
+	AllowedPorts []uint16
+	// Allow lists ranges that may be connected to even though they are blocked
+	// by default, such as an internal documentation server. It does not
+	// override Deny.
+	Allow []netip.Prefix
+	// Deny lists ranges that are blocked in addition to the built-in ranges.
+	// It takes precedence over Allow, so a host can be carved out of an
+	// allowed range.
+	Deny []netip.Prefix
+}
+
+// Default returns the strict policy: only http and https on the standard
+// web ports, to public addresses.
+func Default() *Policy {
+	return &Policy{
+		AllowedSchemes: []string{"http", "https"},
+		AllowedPorts:   []uint16{80, 443, 8080, 8443},
+	}
+}
+
+// PolicyFromEnv returns the Default policy adjusted by the deployment's
+// configuration:
+//
+//	SSRF_ALLOW_CIDRS    comma-separated ranges or addresses to permit
+//	SSRF_DENY_CIDRS     comma-separated ranges or addresses to block, even
+//	                    within SSRF_ALLOW_CIDRS
+//	SSRF_ALLOWED_PORTS  comma-separated ports, or "*" for any port
+func PolicyFromEnv() (*Policy, error) {
+	p := Default()
+
+	var err error
+	if p.Allow, err = parsePrefixes(os.Getenv("SSRF_ALLOW_CIDRS")); err != nil {
+		return nil, fmt.Errorf("invalid SSRF_ALLOW_CIDRS: %w", err)
+	}
+	if p.Deny, err = parsePrefixes(os.Getenv("SSRF_DENY_CIDRS")); err != nil {
+		return nil, fmt.Errorf("invalid SSRF_DENY_CIDRS: %w", err)
+	}
+
+	switch ports := strings.TrimSpace(os.Getenv("SSRF_ALLOWED_PORTS")); ports {
+	case "":
+	case "*":
+		p.AllowedPorts = nil
+	default:
+		p.AllowedPorts = nil
+		for _, field := range strings.Split(ports, ",") {
+			port, err := strconv.ParseUint(strings.TrimSpace(field), 10, 16)
+			if err != nil || port == 0 {
+				return nil, fmt.Errorf("invalid SSRF_ALLOWED_PORTS: bad port %q", field)
+			}
+			p.AllowedPorts = append(p.AllowedPorts, uint16(port))
+		}
+	}
+	return p, nil
+}
+
+// CheckURL validates a URL before it is requested: its scheme, its port, and
+// its host when that is an IP address. Hosts written in alternate IPv4
+// encodings, such as 2852039166 or 0xa9.0xfe.0xa9.0xfe, are decoded and checked
+// as addresses. Host names are checked when they are dialled, by CheckDial.
+func (p *Policy) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.AllowedSchemes) > 0 && !slices.Contains(p.AllowedSchemes, scheme) {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrBlocked, u.Scheme)
+	}
+
+	port, err := urlPort(u)
+	if err != nil {
+		return err
+	}
+	if err := p.checkPort(port); err != nil {
+		return err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		// Always resolves to loopback, so it is checked as such without a lookup
+		return p.CheckAddr(netip.IPv6Loopback())
+	}
+	if addr, ok := parseHost(host); ok {
+		return p.CheckAddr(addr)
+	}
+	return nil
+}
+
+// CheckAddr reports whether addr may be connected to. Deny is checked first,
+// so a range that is both allowed and denied is blocked; Allow then overrides
+// the built-in ranges.
+func (p *Policy) CheckAddr(addr netip.Addr) error {
+	addr = addr.WithZone("").Unmap()
+	if !addr.IsValid() {
+		return fmt.Errorf("%w: invalid address", ErrBlocked)
+	}
+
+	if containsAddr(p.Deny, addr) {
+		return fmt.Errorf("%w: %s", ErrBlocked, addr)
+	}
+	if containsAddr(p.Allow, addr) {
+		return nil
+	}
+	if containsAddr(blockedRanges, addr) {
+		return fmt.Errorf("%w: %s", ErrBlocked, addr)
+	}
+
+	// Translated addresses reach the IPv4 address they embed
+	if embedded, ok := embeddedIPv4(addr); ok {
+		if err := p.CheckAddr(embedded); err != nil {
+			return fmt.Errorf("%w (embedded in %s)", err, addr)
+		}
+	}
+	return nil
+}
+
+// CheckDial reports whether a connection to the resolved address may be made.
+func (p *Policy) CheckDial(network string, addr netip.AddrPort) error {
+	if err := p.checkPort(addr.Port()); err != nil {
+		return err
+	}
+	return p.CheckAddr(addr.Addr())
+}
+
+// checkPort reports whether port may be connected to.
+func (p *Policy) checkPort(port uint16) error {
+	if len(p.AllowedPorts) > 0 && !slices.Contains(p.AllowedPorts, port) {
+		return fmt.Errorf("%w: port %d is not allowed", ErrBlocked, port)
+	}
+	return nil
+}
+
+// urlPort returns the explicit port of u or the default port of its scheme.
+func urlPort(u *url.URL) (uint16, error) {
+	if s := u.Port(); s != "" {
+		port, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid port %q", ErrBlocked, s)
+		}
+		return uint16(port), nil
+	}
+	if strings.EqualFold(u.Scheme, "http") {
+		return 80, nil
+	}
+	return 443, nil
+}
+
+// parseHost parses a URL host as an IP address, accepting the IPv4 forms
+// understood by inet_aton: one to four dot-separated parts, each in decimal,
+// octal (leading 0) or hexadecimal (leading 0x).
+func parseHost(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	var value uint64
+	for i, part := range parts {
+		n, ok := parseIPv4Part(part)
+		if !ok {
+			return netip.Addr{}, false
+		}
+		// The last part fills all remaining bytes of the address
+		bits := 8
+		if i == len(parts)-1 {
+			bits = 8 * (4 - i)
+		}
+		if n >= 1<<bits {
+			return netip.Addr{}, false
+		}
+		value = value<<bits | n
+	}
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true
+}
+
+// parseIPv4Part parses one part of an inet_aton style IPv4 address.
+func parseIPv4Part(part string) (uint64, bool) {
+	base := 10
+	digits := part
+	switch {
+	case strings.HasPrefix(part, "0x") || strings.HasPrefix(part, "0X"):
+		base, digits = 16, part[2:]
+	case len(part) > 1 && part[0] == '0':
+		base, digits = 8, part[1:]
+	}
+	if digits == "" && base != 8 {
+		return 0, false
+	}
+	if digits == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseUint(digits, base, 32)
+	return n, err == nil
+}
+
+// embeddedIPv4 returns the IPv4 address carried by a NAT64 or 6to4 address.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+	switch {
+	case !addr.Is6():
+		return netip.Addr{}, false
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	}
+	return netip.Addr{}, false
+}
+
+// containsAddr reports whether any of prefixes contains addr.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefixes parses a comma-separated list of CIDR ranges or single addresses.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap()
+			field = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
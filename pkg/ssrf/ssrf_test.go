@@ -0,0 +1,212 @@
+package ssrf
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string // "" if the host is not an address
+	}{
+		{"169.254.169.254", "169.254.169.254"},
+		{"::1", "::1"},
+		{"2852039166", "169.254.169.254"},
+		{"0xa9fea9fe", "169.254.169.254"},
+		{"0xa9.0xfe.0xa9.0xfe", "169.254.169.254"},
+		{"0251.0376.0251.0376", "169.254.169.254"},
+		{"0251.254.0xa9.0376", "169.254.169.254"},
+		{"127.1", "127.0.0.1"},
+		{"127.0.1", "127.0.0.1"},
+		{"10.0x10203", "10.1.2.3"},
+		{"017700000001", "127.0.0.1"},
+		{"0", "0.0.0.0"},
+		{"00", "0.0.0.0"},
+		{"4294967295", "255.255.255.255"},
+		{"4294967296", ""},
+		{"256.0.0.1", ""},
+		{"127.0x100.1", ""},
+		{"1.2.3.4.5", ""},
+		{"08.0.0.1", ""},
+		{"0x", ""},
+		{"0xg1", ""},
+		{"1..2", ""},
+		{"example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			addr, ok := parseHost(tt.host)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("parseHost(%q) = %s, want no address", tt.host, addr)
+				}
+				return
+			}
+			if !ok || addr != netip.MustParseAddr(tt.want) {
+				t.Fatalf("parseHost(%q) = %s, %v, want %s", tt.host, addr, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://example.com/", false},
+		{"http://93.184.215.14/", false},
+		{"http://2852039166/latest/meta-data/", true},
+		{"http://0xa9.0xfe.0xa9.0xfe/", true},
+		{"http://0251.0376.0251.0376/", true},
+		{"http://127.1/", true},
+		{"http://localhost/", true},
+		{"http://app.localhost./", true},
+		{"http://[::ffff:127.0.0.1]/", true},
+		{"http://[fd00:c1::a9fe:a9fe]/", true},
+		{"http://example.com:22/", true},
+		{"ftp://example.com/", true},
+		{"file:///etc/passwd", true},
+	}
+	p := Default()
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.CheckURL(u)
+			if tt.blocked != errors.Is(err, ErrBlocked) {
+				t.Fatalf("CheckURL(%s) = %v, want blocked %v", tt.url, err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestCheckAddrEmbedded(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		// NAT64
+		{"64:ff9b::5db8:d70e", false},  // 93.184.215.14
+		{"64:ff9b::a9fe:a9fe", true},   // 169.254.169.254
+		{"64:ff9b::7f00:1", true},      // 127.0.0.1
+		{"64:ff9b::10.0.0.1", true},    // 10.0.0.1
+		{"64:ff9b::c0a8:101", true},    // 192.168.1.1
+		{"64:ff9b:1::5db8:d70e", true}, // Local-use NAT64 is blocked outright
+		// 6to4
+		{"2002:5db8:d70e::1", false}, // 93.184.215.14
+		{"2002:a9fe:a9fe::", true},   // 169.254.169.254
+		{"2002:7f00:1::1", true},     // 127.0.0.1
+		{"2002:ac10:1:1::1", true},   // 172.16.0.1
+		{"2002:6440:1::", true},      // 100.64.0.1
+		// IPv4-mapped and IPv4-compatible
+		{"::ffff:169.254.169.254", true},
+		{"::ffff:93.184.215.14", false},
+		{"::169.254.169.254", true},
+		// Not translated
+		{"2606:2800:220:1::1", false},
+	}
+	p := Default()
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := p.CheckAddr(netip.MustParseAddr(tt.addr))
+			if tt.blocked != errors.Is(err, ErrBlocked) {
+				t.Fatalf("CheckAddr(%s) = %v, want blocked %v", tt.addr, err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestCheckAddrAllowDeny(t *testing.T) {
+	p := &Policy{
+		Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("198.51.100.7/32")},
+		Deny:  []netip.Prefix{netip.MustParsePrefix("10.0.5.0/24"), netip.MustParsePrefix("93.184.215.0/24")},
+	}
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"10.1.2.3", false},          // Allowed
+		{"10.0.5.1", true},           // Denied within an allowed range
+		{"::ffff:10.0.5.1", true},    // Denied, mapped
+		{"198.51.100.7", false},      // Allowed within a built-in range
+		{"198.51.100.8", true},       // Built-in range
+		{"93.184.215.14", true},      // Denied public address
+		{"64:ff9b::5db8:d70e", true}, // Denied through NAT64
+		{"2002:a00:501::", true},     // Denied through 6to4
+		{"64:ff9b::a01:203", false},  // Allowed through NAT64
+		{"8.8.8.8", false},           // Public
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := p.CheckAddr(netip.MustParseAddr(tt.addr))
+			if tt.blocked != errors.Is(err, ErrBlocked) {
+				t.Fatalf("CheckAddr(%s) = %v, want blocked %v", tt.addr, err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestAllowAddrPorts(t *testing.T) {
+	v := AllowAddrPorts(Default(),
+		netip.MustParseAddrPort("127.0.0.1:41234"),
+		netip.MustParseAddrPort("[::ffff:10.0.0.2]:9000"),
+		netip.MustParseAddrPort("[::1]:8081"),
+	)
+
+	urls := []struct {
+		url     string
+		blocked bool
+	}{
+		{"http://127.0.0.1:41234/page", false},
+		{"http://127.0.0.1:41235/page", true},
+		{"http://127.0.0.2:41234/page", true},
+		{"http://10.0.0.2:9000/", false},
+		{"http://[::ffff:10.0.0.2]:9000/", false},
+		{"http://[::1]:8081/", false},
+		{"http://[::1]/", true},
+		{"http://localhost:41234/", true}, // Only literal addresses are matched
+		{"https://example.com/", false},   // Deferred to the base policy
+		{"https://example.com:9000/", true},
+	}
+	for _, tt := range urls {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = v.CheckURL(u)
+			if tt.blocked != errors.Is(err, ErrBlocked) {
+				t.Fatalf("CheckURL(%s) = %v, want blocked %v", tt.url, err, tt.blocked)
+			}
+		})
+	}
+
+	dials := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"127.0.0.1:41234", false},
+		{"[::ffff:127.0.0.1]:41234", false},
+		{"127.0.0.1:80", true},
+		{"10.0.0.2:9000", false},
+		{"10.0.0.2:9001", true},
+		{"[::1]:8081", false},
+		{"93.184.215.14:443", false},
+		{"93.184.215.14:9000", true},
+	}
+	for _, tt := range dials {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := v.CheckDial("tcp", netip.MustParseAddrPort(tt.addr))
+			if tt.blocked != errors.Is(err, ErrBlocked) {
+				t.Fatalf("CheckDial(%s) = %v, want blocked %v", tt.addr, err, tt.blocked)
+			}
+		})
+	}
+}